@@ -1,19 +1,69 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"gin-prometheus-grafana/internal/auth"
+	"gin-prometheus-grafana/internal/cache"
+	"gin-prometheus-grafana/internal/events"
+	"gin-prometheus-grafana/internal/graphqlapi"
+	"gin-prometheus-grafana/internal/grpcserver"
 	"gin-prometheus-grafana/internal/handlers"
+	"gin-prometheus-grafana/internal/loadshed"
 	"gin-prometheus-grafana/internal/middleware"
+	"gin-prometheus-grafana/internal/openapi"
+	"gin-prometheus-grafana/internal/outbox"
+	"gin-prometheus-grafana/internal/ratelimit"
 	"gin-prometheus-grafana/internal/repository"
+	"gin-prometheus-grafana/internal/tlsreload"
+	"gin-prometheus-grafana/internal/webhooks"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// bookPolicy maps every route in the books group to the roles allowed to call
+// it: readers may only read, editors may also create and update, and admins
+// may additionally delete and run bulk or recovery operations.
+var bookPolicy = auth.NewPolicy(
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books", Query: "include_deleted", Roles: []string{auth.RoleAdmin}, Scopes: []string{"books:admin"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/:id", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/isbn/:isbn", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/:id/history", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/events", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/ws", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/export", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:read"}},
+	auth.Rule{Method: http.MethodPost, Route: "/api/v1/books", Roles: []string{auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:write"}},
+	auth.Rule{Method: http.MethodPut, Route: "/api/v1/books/:id", Roles: []string{auth.RoleEditor, auth.RoleAdmin}, Scopes: []string{"books:write"}},
+	auth.Rule{Method: http.MethodDelete, Route: "/api/v1/books/:id", Roles: []string{auth.RoleAdmin}, Scopes: []string{"books:admin"}},
+	auth.Rule{Method: http.MethodPost, Route: "/api/v1/books/:id/restore", Roles: []string{auth.RoleAdmin}, Scopes: []string{"books:admin"}},
+	auth.Rule{Method: http.MethodPost, Route: "/api/v1/books/import", Roles: []string{auth.RoleAdmin}, Scopes: []string{"books:admin"}},
+)
+
+// webhookPolicy restricts webhook management, which exposes partner URLs and
+// signing secrets, to admins.
+var webhookPolicy = auth.NewPolicy(
+	auth.Rule{Method: http.MethodPost, Route: "/api/v1/webhooks", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/webhooks", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/webhooks/:id", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
+	auth.Rule{Method: http.MethodPut, Route: "/api/v1/webhooks/:id", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
+	auth.Rule{Method: http.MethodDelete, Route: "/api/v1/webhooks/:id", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/webhooks/:id/deliveries", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
+	auth.Rule{Method: http.MethodPost, Route: "/api/v1/webhooks/:id/deliveries/:delivery_id/redeliver", Roles: []string{auth.RoleAdmin}, Scopes: []string{"webhooks:admin"}},
 )
 
 func main() {
@@ -31,14 +81,250 @@ func main() {
 
 	// Initialize repository and handlers
 	bookRepo := repository.NewBookRepository(db)
-	bookHandler := handlers.NewBookHandler(bookRepo)
 
-	// Initialize Gin router
-	r := gin.Default()
+	// Queue every write in the outbox once per consumer: the configured
+	// publisher and, with webhooks enabled, the webhook dispatcher. Each has its
+	// own relay, so one stalling does not hold up the other
+	type outboxConsumer struct {
+		name      string
+		publisher outbox.Publisher
+	}
+	var consumers []outboxConsumer
+	if getEnvBool("OUTBOX_ENABLED", false) {
+		publisher, err := buildOutboxPublisher()
+		if err != nil {
+			log.Fatal("Failed to configure outbox publisher:", err)
+		}
+		consumers = append(consumers, outboxConsumer{outbox.BrokerConsumer, publisher})
+	}
+	webhooksEnabled := getEnvBool("WEBHOOKS_ENABLED", false)
+	webhookRepo := repository.NewWebhookRepository(db)
+	if webhooksEnabled {
+		consumers = append(consumers, outboxConsumer{webhooks.OutboxConsumer, webhooks.NewDispatcher(webhookRepo)})
+		deliveryCfg := webhooks.DefaultDelivererConfig
+		deliveryCfg.PollInterval = getEnvDuration("WEBHOOKS_POLL_INTERVAL", deliveryCfg.PollInterval)
+		deliveryCfg.BatchSize = getEnvInt("WEBHOOKS_BATCH_SIZE", deliveryCfg.BatchSize)
+		deliveryCfg.Concurrency = getEnvInt("WEBHOOKS_CONCURRENCY", deliveryCfg.Concurrency)
+		deliveryCfg.Timeout = getEnvDuration("WEBHOOKS_TIMEOUT", deliveryCfg.Timeout)
+		deliveryCfg.MaxAttempts = getEnvInt("WEBHOOKS_MAX_ATTEMPTS", deliveryCfg.MaxAttempts)
+		deliveryCfg.BaseBackoff = getEnvDuration("WEBHOOKS_BASE_BACKOFF", deliveryCfg.BaseBackoff)
+		deliveryCfg.MaxBackoff = getEnvDuration("WEBHOOKS_MAX_BACKOFF", deliveryCfg.MaxBackoff)
+		deliveryCfg.AllowPrivateTargets = getEnvBool("WEBHOOKS_ALLOW_PRIVATE_TARGETS", false)
+		go webhooks.NewDeliverer(webhookRepo, deliveryCfg).Run(context.Background())
+	}
+	if len(consumers) > 0 {
+		relayCfg := outbox.DefaultRelayConfig
+		relayCfg.PollInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", relayCfg.PollInterval)
+		relayCfg.BatchSize = getEnvInt("OUTBOX_BATCH_SIZE", relayCfg.BatchSize)
+		relayCfg.Lease = getEnvDuration("OUTBOX_LEASE", relayCfg.Lease)
+		relayCfg.PublishTimeout = getEnvDuration("OUTBOX_PUBLISH_TIMEOUT", relayCfg.PublishTimeout)
+		relayCfg.BaseBackoff = getEnvDuration("OUTBOX_BASE_BACKOFF", relayCfg.BaseBackoff)
+		relayCfg.MaxBackoff = getEnvDuration("OUTBOX_MAX_BACKOFF", relayCfg.MaxBackoff)
+		relayCfg.Retention = getEnvDuration("OUTBOX_RETENTION", relayCfg.Retention)
+		var names []string
+		for _, c := range consumers {
+			names = append(names, c.name)
+			go outbox.NewRelay(db, c.name, c.publisher, relayCfg).Run(context.Background())
+		}
+		bookRepo.EnableOutbox(names...)
+	}
+	var bookStore repository.BookStore = bookRepo
+	if getEnvBool("CACHE_ENABLED", false) {
+		bookCache := cache.NewLRU("books", getEnvInt("CACHE_SIZE", 10000), getEnvDuration("CACHE_TTL", time.Minute))
+		bookStore = repository.NewCachedBookRepository(bookRepo, bookCache)
+	}
+	// Change notifications for the event stream and WebSocket subscribers,
+	// published by the store so that every API's writes are announced
+	eventsEnabled := getEnvBool("EVENTS_ENABLED", false)
+	websocketEnabled := getEnvBool("WEBSOCKET_ENABLED", false)
+	var bookEvents *events.Bus
+	if eventsEnabled || websocketEnabled {
+		bookEvents = events.NewBus(getEnvInt("EVENTS_HISTORY_SIZE", 1000), getEnvInt("EVENTS_SUBSCRIBER_BUFFER", 64))
+		bookStore = repository.NewEventedBookStore(bookStore, bookEvents)
+	}
+
+	// Permanently remove soft-deleted books once their retention has passed
+	retention := getEnvDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour)
+	purgeInterval := getEnvDuration("PURGE_INTERVAL", time.Hour)
+	go repository.NewPurger(bookRepo, retention, purgeInterval).Run(context.Background())
+
+	// Register custom request validators
+	if err := handlers.RegisterValidators(); err != nil {
+		log.Fatal("Failed to register validators:", err)
+	}
+
+	// Credentials are checked the same way by the REST and gRPC APIs
+	var authenticators []auth.Authenticator
+	if getEnvBool("AUTH_ENABLED", false) {
+		authenticators, err = buildAuthenticators()
+		if err != nil {
+			log.Fatal("Failed to configure authentication:", err)
+		}
+	}
+
+	r, err := newRouter(routerDeps{bookStore: bookStore, bookEvents: bookEvents, webhookRepo: webhookRepo, authenticators: authenticators})
+	if err != nil {
+		log.Fatal("Failed to set up routes:", err)
+	}
+
+	// gRPC BookService on its own port, sharing the repository with REST
+	if getEnvBool("GRPC_ENABLED", false) {
+		grpcPort := os.Getenv("GRPC_PORT")
+		if grpcPort == "" {
+			grpcPort = "50051"
+		}
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC:", err)
+		}
+		access := grpcserver.Access{Authenticators: authenticators, RoleSource: authzRoleSource()}
+		if getEnvBool("AUTHZ_ENABLED", false) {
+			access.Policy = bookPolicy
+		}
+		grpcServer := grpcserver.NewServer(bookStore, access)
+		go func() {
+			log.Printf("gRPC server starting on port %s", grpcPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal("Failed to start gRPC server:", err)
+			}
+		}()
+	}
+
+	// Start server
+	port := os.Getenv("SERVER_PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+
+	// Serve TLS, and verify client certificates when a CA bundle is given;
+	// certificates are reloaded when the files change on disk
+	if certFile := os.Getenv("TLS_CERT_FILE"); certFile != "" {
+		var reloader *tlsreload.Reloader
+		reloader, err = tlsreload.New(tlsreload.Config{
+			CertFile:          certFile,
+			KeyFile:           os.Getenv("TLS_KEY_FILE"),
+			ClientCAFile:      os.Getenv("TLS_CLIENT_CA_FILE"),
+			RequireClientCert: getEnvBool("TLS_REQUIRE_CLIENT_CERT", true),
+		})
+		if err != nil {
+			log.Fatal("Failed to load TLS certificates:", err)
+		}
+		go reloader.Run(context.Background(), getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second))
+		srv.TLSConfig = reloader.TLSConfig()
+
+		// HTTP/3 shares the TLS configuration and listens on UDP; TCP
+		// responses advertise it with Alt-Svc
+		if getEnvBool("HTTP3_ENABLED", false) {
+			h3 := &http3.Server{
+				Addr:      ":" + port,
+				Handler:   r,
+				TLSConfig: srv.TLSConfig,
+			}
+			srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				h3.SetQUICHeaders(w.Header())
+				r.ServeHTTP(w, req)
+			})
+			go func() {
+				log.Printf("HTTP/3 listener starting on udp port %s", port)
+				if err := h3.ListenAndServe(); err != nil {
+					log.Fatal("Failed to start HTTP/3 listener:", err)
+				}
+			}()
+		}
+
+		log.Printf("Server starting on port %s with TLS", port)
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		// Accept HTTP/2 without TLS (prior knowledge or Upgrade: h2c)
+		if getEnvBool("H2C_ENABLED", false) {
+			srv.Handler = h2c.NewHandler(r, &http2.Server{})
+		}
+		log.Printf("Server starting on port %s", port)
+		err = srv.ListenAndServe()
+	}
+	if err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
+
+// undocumentedRoutes are served without an OpenAPI description: operational
+// endpoints, the document itself, and GraphQL, which describes itself through
+// introspection.
+var undocumentedRoutes = []string{"/metrics", "/openapi.json", "/docs", "/graphql"}
+
+// routerDeps are the services the HTTP routes are built on. bookEvents must be
+// set when EVENTS_ENABLED or WEBSOCKET_ENABLED is, and webhookRepo when
+// WEBHOOKS_ENABLED is. With no authenticators the API is unauthenticated.
+type routerDeps struct {
+	bookStore      repository.BookStore
+	bookEvents     *events.Bus
+	webhookRepo    *repository.WebhookRepository
+	authenticators []auth.Authenticator
+}
+
+// newRouter builds the HTTP router and its middleware from the environment.
+// TestRoutesAreDocumented checks that every route it registers is described
+// in the OpenAPI document.
+func newRouter(deps routerDeps) (*gin.Engine, error) {
+	bookHandler := handlers.NewBookHandler(deps.bookStore)
+
+	// Initialize Gin router. X-Forwarded-For is only believed from the
+	// proxies listed in TRUSTED_PROXIES, so clients cannot pick the IP that
+	// rate limits and logs see
+	r := gin.New()
+	if err := r.SetTrustedProxies(getEnvList("TRUSTED_PROXIES", nil)); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	r.Use(gin.Logger())
+
+	// Tag every request with an ID and actor for logs and the audit trail
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Actor())
+	r.Use(middleware.ClientIdentity())
 
 	// Add Prometheus middleware
 	r.Use(middleware.PrometheusMiddleware())
 
+	// Recover from handler panics inside the metrics middleware so the
+	// resulting 500 is still counted
+	r.Use(middleware.Recovery(os.Getenv("CRASH_DUMP_DIR")))
+
+	// Security headers on every response, and CORS for browser clients
+	if getEnvBool("SECURITY_HEADERS_ENABLED", true) {
+		securityCfg := middleware.DefaultSecurityHeadersConfig
+		securityCfg.HSTSMaxAge = getEnvDuration("HSTS_MAX_AGE", securityCfg.HSTSMaxAge)
+		if csp := os.Getenv("CONTENT_SECURITY_POLICY"); csp != "" {
+			securityCfg.ContentSecurityPolicy = csp
+		}
+		if policy := os.Getenv("REFERRER_POLICY"); policy != "" {
+			securityCfg.ReferrerPolicy = policy
+		}
+		r.Use(middleware.SecurityHeaders(securityCfg))
+	}
+	if getEnvBool("CORS_ENABLED", false) {
+		r.Use(middleware.CORS(middleware.CORSConfig{
+			AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", nil),
+			AllowedMethods:   getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE"}),
+			AllowedHeaders:   getEnvList("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "X-Actor", "If-None-Match", "If-Modified-Since"}),
+			ExposedHeaders:   getEnvList("CORS_EXPOSED_HEADERS", []string{"ETag", "Last-Modified", "X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}),
+			AllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		}))
+	}
+
+	// Compress large text responses; runs inside the metrics middleware so
+	// response sizes are recorded both before and after encoding
+	if getEnvBool("COMPRESSION_ENABLED", false) {
+		compressCfg := middleware.DefaultCompressConfig
+		compressCfg.MinSize = getEnvInt("COMPRESSION_MIN_SIZE", compressCfg.MinSize)
+		r.Use(middleware.Compress(compressCfg))
+	}
+
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
@@ -47,28 +333,333 @@ func main() {
 	// Metrics endpoint for Prometheus
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// OpenAPI contract and a browsable rendering of it
+	apiDoc := openapi.BookAPI()
+	r.GET("/openapi.json", openapi.Handler(apiDoc))
+	r.GET("/docs", openapi.DocsHandler("/openapi.json"))
+
+	// Token buckets for the per-group rate limiters. rateLimit returns the
+	// limiter for one route group, or nothing when rate limiting is off
+	rateLimitStore := ratelimit.NewMemoryStore()
+	rateLimitEnabled := getEnvBool("RATE_LIMIT_ENABLED", false)
+	rateLimitKey := rateLimitKeyFunc(os.Getenv("RATE_LIMIT_KEY"))
+	rateLimit := func(group, env string, def ratelimit.Limit) []gin.HandlerFunc {
+		if !rateLimitEnabled {
+			return nil
+		}
+		return []gin.HandlerFunc{middleware.RateLimit(group, rateLimitStore, getEnvLimit(env, def), rateLimitKey)}
+	}
+
 	// API routes
 	api := r.Group("/api/v1")
+	bulkRoutes := []string{"/api/v1/books/export", "/api/v1/books/import"}
+	var bulkLimit gin.HandlerFunc = func(c *gin.Context) { c.Next() }
+	if getEnvBool("CONCURRENCY_LIMIT_ENABLED", false) {
+		limiter := loadshed.NewLimiter(loadshed.Config{
+			InitialLimit:  getEnvInt("CONCURRENCY_INITIAL_LIMIT", 20),
+			MinLimit:      getEnvInt("CONCURRENCY_MIN_LIMIT", 5),
+			MaxLimit:      getEnvInt("CONCURRENCY_MAX_LIMIT", 200),
+			TargetLatency: getEnvDuration("CONCURRENCY_TARGET_LATENCY", 250*time.Millisecond),
+			MaxQueue:      getEnvInt("CONCURRENCY_MAX_QUEUE", 50),
+			QueueTimeout:  getEnvDuration("CONCURRENCY_QUEUE_TIMEOUT", 100*time.Millisecond),
+		})
+		api.Use(middleware.ConcurrencyLimit("default", limiter, bulkRoutes...))
+
+		// Bulk transfers run for seconds by design, which the adaptive limit
+		// would read as overload, so they share a small fixed pool instead
+		bulk := getEnvInt("CONCURRENCY_BULK_LIMIT", 4)
+		bulkLimiter := loadshed.NewLimiter(loadshed.Config{
+			InitialLimit: bulk,
+			MinLimit:     bulk,
+			MaxLimit:     bulk,
+			MaxQueue:     getEnvInt("CONCURRENCY_BULK_MAX_QUEUE", 4),
+			QueueTimeout: getEnvDuration("CONCURRENCY_BULK_QUEUE_TIMEOUT", time.Second),
+		})
+		bulkLimit = middleware.ConcurrencyLimit("bulk", bulkLimiter)
+	}
+	var authenticate []gin.HandlerFunc
+	if len(deps.authenticators) > 0 {
+		authenticate = append(authenticate, middleware.Authenticate(deps.authenticators...))
+		api.Use(authenticate...)
+	}
+	authzEnabled := getEnvBool("AUTHZ_ENABLED", false)
+	roleSource := authzRoleSource()
 	{
 		books := api.Group("/books")
+		books.Use(rateLimit("books", "RATE_LIMIT_BOOKS", ratelimit.Limit{Rate: 50, Burst: 100})...)
+		if authzEnabled {
+			books.Use(middleware.Authorize(bookPolicy, roleSource))
+		}
+		if getEnvBool("CONTRACT_VALIDATION_ENABLED", false) {
+			books.Use(middleware.ValidateContract(apiDoc, middleware.ContractConfig{
+				MaxBodyBytes:      int64(getEnvInt("CONTRACT_MAX_BODY_BYTES", 1<<20)),
+				ValidateResponses: getEnvBool("CONTRACT_VALIDATE_RESPONSES", gin.Mode() == gin.TestMode),
+			}))
+		}
+		// Per-route deadlines: quick lookups, everything else, and bulk transfers
+		var readTimeout, defaultTimeout, bulkTimeout time.Duration
+		if getEnvBool("REQUEST_TIMEOUTS_ENABLED", false) {
+			readTimeout = getEnvDuration("REQUEST_TIMEOUT_READ", 2*time.Second)
+			defaultTimeout = getEnvDuration("REQUEST_TIMEOUT_DEFAULT", 10*time.Second)
+			bulkTimeout = getEnvDuration("REQUEST_TIMEOUT_BULK", 30*time.Second)
+		}
 		{
-			books.POST("", bookHandler.CreateBook)
-			books.GET("", bookHandler.GetAllBooks)
-			books.GET("/:id", bookHandler.GetBookByID)
-			books.PUT("/:id", bookHandler.UpdateBook)
-			books.DELETE("/:id", bookHandler.DeleteBook)
+			books.POST("", middleware.Timeout(defaultTimeout), bookHandler.CreateBook)
+			books.GET("", middleware.Timeout(defaultTimeout), bookHandler.GetAllBooks)
+			books.GET("/export", bulkLimit, middleware.Timeout(bulkTimeout), bookHandler.ExportBooks)
+			books.POST("/import", bulkLimit, middleware.Timeout(bulkTimeout), bookHandler.ImportBooks)
+			books.GET("/isbn/:isbn", middleware.Timeout(readTimeout), bookHandler.GetBookByISBN)
+			books.GET("/:id", middleware.Timeout(readTimeout), bookHandler.GetBookByID)
+			books.PUT("/:id", middleware.Timeout(defaultTimeout), bookHandler.UpdateBook)
+			books.DELETE("/:id", middleware.Timeout(defaultTimeout), bookHandler.DeleteBook)
+			books.POST("/:id/restore", middleware.Timeout(defaultTimeout), bookHandler.RestoreBook)
+			books.GET("/:id/history", middleware.Timeout(readTimeout), bookHandler.GetBookHistory)
+		}
+	}
+	if getEnvBool("WEBHOOKS_ENABLED", false) {
+		webhookHandler := handlers.NewWebhookHandler(deps.webhookRepo, webhooks.TargetGuard{
+			AllowPrivate: getEnvBool("WEBHOOKS_ALLOW_PRIVATE_TARGETS", false),
+		})
+		hooks := api.Group("/webhooks")
+		hooks.Use(rateLimit("webhooks", "RATE_LIMIT_WEBHOOKS", ratelimit.Limit{Rate: 5, Burst: 20})...)
+		if authzEnabled {
+			hooks.Use(middleware.Authorize(webhookPolicy, roleSource))
+		}
+		{
+			hooks.POST("", webhookHandler.CreateWebhook)
+			hooks.GET("", webhookHandler.GetAllWebhooks)
+			hooks.GET("/:id", webhookHandler.GetWebhookByID)
+			hooks.PUT("/:id", webhookHandler.UpdateWebhook)
+			hooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+			hooks.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+			hooks.POST("/:id/deliveries/:delivery_id/redeliver", webhookHandler.Redeliver)
 		}
 	}
 
-	// Start server
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	// Live change streams. Connections stay open indefinitely, so the routes
+	// are registered outside the books group to skip its rate limit, contract
+	// validation and timeouts, and outside /api/v1 to skip the concurrency
+	// limiter
+	streamRoute := func(handler gin.HandlerFunc) []gin.HandlerFunc {
+		chain := append([]gin.HandlerFunc{}, authenticate...)
+		if authzEnabled {
+			chain = append(chain, middleware.Authorize(bookPolicy, roleSource))
+		}
+		return append(chain, handler)
+	}
+	if getEnvBool("EVENTS_ENABLED", false) {
+		heartbeat := getEnvDuration("EVENTS_HEARTBEAT_INTERVAL", 15*time.Second)
+		r.GET("/api/v1/books/events", streamRoute(events.Handler(deps.bookEvents, heartbeat))...)
+	}
+	if getEnvBool("WEBSOCKET_ENABLED", false) {
+		wsCfg := events.DefaultWebSocketConfig
+		wsCfg.PingInterval = getEnvDuration("WEBSOCKET_PING_INTERVAL", wsCfg.PingInterval)
+		wsCfg.PongTimeout = getEnvDuration("WEBSOCKET_PONG_TIMEOUT", wsCfg.PongTimeout)
+		wsCfg.WriteTimeout = getEnvDuration("WEBSOCKET_WRITE_TIMEOUT", wsCfg.WriteTimeout)
+		wsCfg.MaxSubscriptions = getEnvInt("WEBSOCKET_MAX_SUBSCRIPTIONS", wsCfg.MaxSubscriptions)
+		wsCfg.AllowedOrigins = getEnvList("WEBSOCKET_ALLOWED_ORIGINS", nil)
+		r.GET("/api/v1/books/ws", streamRoute(events.WebSocketHandler(deps.bookEvents, wsCfg))...)
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// GraphQL over the same repository; resolvers apply bookPolicy themselves
+	// since one request may run several operations
+	if getEnvBool("GRAPHQL_ENABLED", false) {
+		var policy *auth.Policy
+		if authzEnabled {
+			policy = bookPolicy
+		}
+		schema, err := graphqlapi.NewSchema(deps.bookStore, policy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+		}
+		graphqlHandler := graphqlapi.Handler(schema, graphqlapi.Limits{
+			MaxDepth:      getEnvInt("GRAPHQL_MAX_DEPTH", 8),
+			MaxComplexity: getEnvInt("GRAPHQL_MAX_COMPLEXITY", 1000),
+		}, func(c *gin.Context) graphqlapi.Caller {
+			principal, _ := middleware.PrincipalFromContext(c)
+			caller := graphqlapi.Caller{Roles: roleSource.Roles(c.Request, principal)}
+			if principal != nil {
+				caller.Scopes = principal.Scopes
+			}
+			return caller
+		})
+		graphqlRoute := append(append([]gin.HandlerFunc{}, authenticate...), rateLimit("graphql", "RATE_LIMIT_GRAPHQL", ratelimit.Limit{Rate: 20, Burst: 40})...)
+		graphqlRoute = append(graphqlRoute, graphqlHandler)
+		r.POST("/graphql", graphqlRoute...)
+		r.GET("/graphql", graphqlRoute...)
+	}
+
+	return r, nil
+}
+
+// getEnvDuration parses a time.Duration from the environment, falling back to
+// def when the variable is unset or malformed.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// getEnvInt parses an integer from the environment, falling back to def when
+// the variable is unset or malformed.
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// getEnvBool parses a boolean from the environment, falling back to def when
+// the variable is unset or malformed.
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %t", key, value, def)
+		return def
+	}
+	return b
+}
+
+// getEnvList splits a comma-separated list from the environment, falling back
+// to def when the variable is unset.
+func getEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvLimit parses a "rate:burst" rate limit from the environment, falling
+// back to def when the variable is unset or malformed.
+func getEnvLimit(key string, def ratelimit.Limit) ratelimit.Limit {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		log.Printf("Invalid %s: %v, using %g:%d", key, err, def.Rate, def.Burst)
+		return def
+	}
+	return limit
+}
+
+// rateLimitKeyFunc maps RATE_LIMIT_KEY to the bucket key; client IP is the default.
+func rateLimitKeyFunc(name string) middleware.RateLimitKeyFunc {
+	switch name {
+	case "api_key":
+		return middleware.RateLimitByAPIKey
+	case "route":
+		return middleware.RateLimitByRoute
+	case "", "ip":
+		return middleware.RateLimitByClientIP
+	default:
+		log.Printf("Unknown RATE_LIMIT_KEY %q, keying by client IP", name)
+		return middleware.RateLimitByClientIP
+	}
+}
+
+// buildAuthenticators configures API key and JWT authentication from the
+// environment. At least one of them must be configured.
+func buildAuthenticators() ([]auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if path := os.Getenv("AUTH_API_KEYS_FILE"); path != "" {
+		keys, err := auth.LoadAPIKeys(path)
+		if err != nil {
+			return nil, err
+		}
+		a, err := auth.NewAPIKeyAuthenticator(keys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+		log.Printf("Loaded %d API keys from %s", len(keys), path)
+	}
+
+	if path := os.Getenv("AUTH_JWKS_FILE"); path != "" {
+		a, err := auth.NewJWTAuthenticator(path, os.Getenv("AUTH_JWT_ISSUER"), os.Getenv("AUTH_JWT_AUDIENCE"))
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+		log.Printf("Loaded JWKS from %s", path)
+	}
+
+	if getEnvBool("AUTH_CLIENT_CERT", false) {
+		authenticators = append(authenticators, auth.NewClientCertAuthenticator())
+	}
+
+	if len(authenticators) == 0 {
+		return nil, fmt.Errorf("AUTH_ENABLED is set but none of AUTH_API_KEYS_FILE, AUTH_JWKS_FILE or AUTH_CLIENT_CERT is configured")
+	}
+	return authenticators, nil
+}
+
+// authzRoleSource reads where callers' roles come from, defaulting to the
+// "roles" claim of the authenticated principal.
+func authzRoleSource() auth.RoleSource {
+	source := auth.RoleSource{Header: os.Getenv("AUTHZ_ROLES_HEADER"), Claim: os.Getenv("AUTHZ_ROLES_CLAIM")}
+	if source.Header == "" && source.Claim == "" {
+		source.Claim = "roles"
+	}
+	return source
+}
+
+// buildOutboxPublisher returns the publisher named by OUTBOX_PUBLISHER.
+func buildOutboxPublisher() (outbox.Publisher, error) {
+	switch kind := os.Getenv("OUTBOX_PUBLISHER"); kind {
+	case "", "log":
+		return outbox.LogPublisher{}, nil
+	case "webhook":
+		url := os.Getenv("OUTBOX_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("OUTBOX_WEBHOOK_URL is required for the webhook publisher")
+		}
+		return outbox.NewWebhookPublisher(url, getEnvDuration("OUTBOX_WEBHOOK_TIMEOUT", 10*time.Second)), nil
+	case "nats":
+		url := os.Getenv("OUTBOX_NATS_URL")
+		if url == "" {
+			url = "nats://localhost:4222"
+		}
+		prefix := os.Getenv("OUTBOX_NATS_SUBJECT_PREFIX")
+		if prefix == "" {
+			prefix = "bookstore"
+		}
+		return outbox.NewNATSPublisher(url, prefix)
+	case "kafka":
+		brokers := getEnvList("OUTBOX_KAFKA_BROKERS", []string{"localhost:9092"})
+		topic := os.Getenv("OUTBOX_KAFKA_TOPIC")
+		if topic == "" {
+			topic = "book-events"
+		}
+		return outbox.NewKafkaPublisher(brokers, topic), nil
+	default:
+		return nil, fmt.Errorf("unknown OUTBOX_PUBLISHER %q, expected log, webhook, nats or kafka", kind)
 	}
 }
 
@@ -100,15 +691,37 @@ func connectDB() (*sql.DB, error) {
 			author VARCHAR(255) NOT NULL,
 			isbn VARCHAR(13) UNIQUE NOT NULL,
 			price DECIMAL(10,2) NOT NULL,
+			currency CHAR(3) NOT NULL DEFAULT 'USD',
 			published_at TIMESTAMP NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP
 		);
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+		CREATE TABLE IF NOT EXISTS book_audit (
+			id BIGSERIAL PRIMARY KEY,
+			book_id INTEGER NOT NULL,
+			operation VARCHAR(16) NOT NULL,
+			actor VARCHAR(255) NOT NULL,
+			request_id VARCHAR(64) NOT NULL DEFAULT '',
+			before_data JSONB,
+			after_data JSONB,
+			changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS book_audit_book_id_idx ON book_audit (book_id, id);
 	`
 
 	if _, err := db.Exec(createTableQuery); err != nil {
 		return nil, fmt.Errorf("failed to create table: %v", err)
 	}
+	if _, err := db.Exec(outbox.Schema); err != nil {
+		return nil, fmt.Errorf("failed to create outbox table: %v", err)
+	}
+	if _, err := db.Exec(webhooks.Schema); err != nil {
+		return nil, fmt.Errorf("failed to create webhook tables: %v", err)
+	}
 
 	log.Println("Database connected and table created successfully")
 	return db, nil
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gin-prometheus-grafana/internal/events"
+	"gin-prometheus-grafana/internal/handlers"
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/openapi"
+	"gin-prometheus-grafana/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesAreDocumented builds the router with every optional route group
+// enabled and fails if any registered route is missing from the OpenAPI
+// document.
+func TestRoutesAreDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := handlers.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"EVENTS_ENABLED", "WEBSOCKET_ENABLED", "WEBHOOKS_ENABLED", "GRAPHQL_ENABLED",
+		"AUTHZ_ENABLED", "RATE_LIMIT_ENABLED", "CONCURRENCY_LIMIT_ENABLED",
+		"CONTRACT_VALIDATION_ENABLED", "REQUEST_TIMEOUTS_ENABLED",
+	} {
+		t.Setenv(name, "true")
+	}
+
+	// Building the routes does not touch the database
+	r, err := newRouter(routerDeps{
+		bookStore:   repository.NewBookRepository(nil),
+		bookEvents:  events.NewBus(10, 10),
+		webhookRepo: repository.NewWebhookRepository(nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if missing := openapi.MissingRoutes(openapi.BookAPI(), r.Routes(), undocumentedRoutes...); len(missing) > 0 {
+		t.Errorf("routes missing from the OpenAPI document: %v", missing)
+	}
+
+	// Every documented operation is served, so the spec does not describe
+	// routes that no longer exist
+	served := map[string]bool{}
+	for _, route := range r.Routes() {
+		served[route.Method+" "+route.Path] = true
+	}
+	for path, item := range openapi.BookAPI().Paths {
+		route := strings.NewReplacer("{", ":", "}", "").Replace(path)
+		for method := range *item {
+			if !served[strings.ToUpper(method)+" "+route] {
+				t.Errorf("documented operation %s %s is not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// stubBookStore serves any book by ID without a database.
+type stubBookStore struct {
+	repository.BookStore
+}
+
+func (stubBookStore) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	return &models.Book{ID: id, Title: "Dune"}, nil
+}
+
+// TestRateLimitIgnoresSpoofedForwardedFor checks that X-Forwarded-For only
+// picks the rate limit bucket when the peer is a trusted proxy.
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	t.Setenv("RATE_LIMIT_BOOKS", "1:1")
+
+	tests := []struct {
+		name           string
+		trustedProxies string
+		want           int
+	}{
+		// httptest requests come from 192.0.2.1
+		{"no trusted proxies", "", http.StatusTooManyRequests},
+		{"peer is a trusted proxy", "192.0.2.0/24", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trustedProxies)
+			r, err := newRouter(routerDeps{
+				bookStore:   stubBookStore{},
+				bookEvents:  events.NewBus(10, 10),
+				webhookRepo: repository.NewWebhookRepository(nil),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var code int
+			for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/books/1", nil)
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				code = w.Code
+			}
+			if code != tt.want {
+				t.Errorf("second request status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+// TestIncludeDeletedNeedsAdminScope checks that a token scoped books:read
+// cannot list soft-deleted books, while one scoped books:admin can.
+func TestIncludeDeletedNeedsAdminScope(t *testing.T) {
+	rule, ok := bookPolicy.MatchRoute(http.MethodGet, "/api/v1/books", url.Values{"include_deleted": {"true"}})
+	if !ok {
+		t.Fatal("no rule for GET /api/v1/books?include_deleted")
+	}
+	if rule.Allows(nil, []string{"books:read"}) {
+		t.Error("books:read may list deleted books")
+	}
+	if !rule.Allows(nil, []string{"books:admin"}) {
+		t.Error("books:admin may not list deleted books")
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is a configured key. Only the SHA-256 hash of the secret is stored.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Hash   string   `json:"hash"`
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyAuthenticator struct {
+	keys   []APIKey
+	hashes [][]byte
+}
+
+func NewAPIKeyAuthenticator(keys []APIKey) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{keys: keys, hashes: make([][]byte, len(keys))}
+	for i, k := range keys {
+		h, err := hex.DecodeString(k.Hash)
+		if err != nil || len(h) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: hash must be a hex-encoded SHA-256 digest", k.ID)
+		}
+		a.hashes[i] = h
+	}
+	return a, nil
+}
+
+// LoadAPIKeys reads a JSON array of APIKey entries from path.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid api keys file %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+// HashAPIKey returns the hex SHA-256 digest stored in the keys file for secret.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *APIKeyAuthenticator) Method() string {
+	return MethodAPIKey
+}
+
+// Authenticate accepts the key from the X-API-Key header or an
+// "Authorization: ApiKey <key>" header.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	secret := r.Header.Get(APIKeyHeader)
+	if secret == "" {
+		if scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "ApiKey") {
+			secret = strings.TrimSpace(value)
+		}
+	}
+	if secret == "" {
+		return nil, ErrNoCredentials
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	match := -1
+	// Compare against every key so timing does not reveal which entry matched.
+	for i, h := range a.hashes {
+		if subtle.ConstantTimeCompare(sum[:], h) == 1 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	key := a.keys[match]
+	return &Principal{
+		Subject: "apikey:" + key.ID,
+		Method:  MethodAPIKey,
+		Roles:   key.Roles,
+		Scopes:  key.Scopes,
+	}, nil
+}
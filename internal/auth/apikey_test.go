@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	// printf 'secret' | sha256sum
+	const want = "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+	if got := HashAPIKey("secret"); got != want {
+		t.Errorf("HashAPIKey(secret) = %s, want %s", got, want)
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	a, err := NewAPIKeyAuthenticator([]APIKey{
+		{ID: "ci", Hash: HashAPIKey("ci-secret"), Roles: []string{RoleEditor}},
+		{ID: "ops", Hash: HashAPIKey("ops-secret"), Roles: []string{RoleAdmin}, Scopes: []string{"books:admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantSubject string
+		wantErr     error
+	}{
+		{"x-api-key header", APIKeyHeader, "ops-secret", "apikey:ops", nil},
+		{"authorization header", "Authorization", "ApiKey ci-secret", "apikey:ci", nil},
+		{"unknown key", APIKeyHeader, "guess", "", ErrInvalidCredentials},
+		{"hash is not a key", APIKeyHeader, HashAPIKey("ops-secret"), "", ErrInvalidCredentials},
+		{"no key", "", "", "", ErrNoCredentials},
+		{"bearer token", "Authorization", "Bearer abc", "", ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			p, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("want %v, got principal %+v, err %v", tt.wantErr, p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Subject != tt.wantSubject || p.Method != MethodAPIKey {
+				t.Errorf("principal = %+v", p)
+			}
+		})
+	}
+}
+
+func TestNewAPIKeyAuthenticatorRejectsBadHash(t *testing.T) {
+	for _, hash := range []string{"", "not-hex", "abcd"} {
+		if _, err := NewAPIKeyAuthenticator([]APIKey{{ID: "bad", Hash: hash}}); err == nil {
+			t.Errorf("hash %q: expected an error", hash)
+		}
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `[{"id": "ci", "hash": "` + HashAPIKey("ci-secret") + `", "roles": ["editor"], "scopes": ["books:write"]}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != "ci" || keys[0].Roles[0] != RoleEditor || keys[0].Scopes[0] != "books:write" {
+		t.Fatalf("keys = %+v", keys)
+	}
+	if _, err := NewAPIKeyAuthenticator(keys); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+// Package auth authenticates API callers with static API keys, JWTs or TLS
+// client certificates and describes the result as a Principal.
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+const (
+	MethodAPIKey     = "api_key"
+	MethodJWT        = "jwt"
+	MethodClientCert = "client_cert"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials of the kind an
+	// Authenticator understands, so the next Authenticator should be tried.
+	ErrNoCredentials = errors.New("no credentials")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated identity behind a request.
+type Principal struct {
+	Subject string                 `json:"subject"`
+	Method  string                 `json:"method"`
+	Roles   []string               `json:"roles"`
+	Scopes  []string               `json:"scopes"`
+	Claims  map[string]interface{} `json:"-"`
+}
+
+// Authenticator validates the credentials carried by a request.
+type Authenticator interface {
+	// Method names the credential type for metrics and logs.
+	Method() string
+	// Authenticate returns ErrNoCredentials when the request has no credentials
+	// for this method, or another error when they are present but invalid.
+	Authenticate(r *http.Request) (*Principal, error)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// ClientIdentity describes the verified TLS client certificate of a request.
+type ClientIdentity struct {
+	CommonName          string    `json:"common_name"`
+	Organization        []string  `json:"organization,omitempty"`
+	OrganizationalUnits []string  `json:"organizational_units,omitempty"`
+	DNSNames            []string  `json:"dns_names,omitempty"`
+	SerialNumber        string    `json:"serial_number"`
+	NotAfter            time.Time `json:"not_after"`
+}
+
+// ClientIdentityFromRequest returns the identity of the client certificate the
+// TLS handshake verified, or nil for plaintext requests and requests without
+// a verified certificate.
+func ClientIdentityFromRequest(r *http.Request) *ClientIdentity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	return &ClientIdentity{
+		CommonName:          cert.Subject.CommonName,
+		Organization:        cert.Subject.Organization,
+		OrganizationalUnits: cert.Subject.OrganizationalUnit,
+		DNSNames:            cert.DNSNames,
+		SerialNumber:        hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotAfter:            cert.NotAfter,
+	}
+}
+
+// ClientCertAuthenticator accepts requests whose TLS client certificate was
+// verified during the handshake. Organizational units named reader, editor or
+// admin become the principal's roles.
+type ClientCertAuthenticator struct{}
+
+func NewClientCertAuthenticator() *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{}
+}
+
+func (a *ClientCertAuthenticator) Method() string {
+	return MethodClientCert
+}
+
+func (a *ClientCertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	id := ClientIdentityFromRequest(r)
+	if id == nil {
+		return nil, ErrNoCredentials
+	}
+	if id.CommonName == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	var roles []string
+	for _, ou := range id.OrganizationalUnits {
+		switch ou {
+		case RoleReader, RoleEditor, RoleAdmin:
+			roles = append(roles, ou)
+		}
+	}
+	return &Principal{
+		Subject: "cert:" + id.CommonName,
+		Method:  MethodClientCert,
+		Roles:   roles,
+		Claims: map[string]interface{}{
+			"serial_number": id.SerialNumber,
+			"dns_names":     id.DNSNames,
+		},
+	}, nil
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// jwtLeeway tolerates small clock differences when checking exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type verificationKey struct {
+	kid    string
+	alg    string
+	secret []byte
+	rsa    *rsa.PublicKey
+}
+
+// JWTAuthenticator validates HS256 and RS256 bearer tokens against a local
+// JWKS document: "oct" keys for HS256 and "RSA" keys for RS256.
+type JWTAuthenticator struct {
+	keys     []verificationKey
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// NewJWTAuthenticator loads the JWKS at path. Issuer and audience are only
+// enforced when non-empty.
+func NewJWTAuthenticator(path, issuer, audience string) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS %s: %v", path, err)
+	}
+
+	a := &JWTAuthenticator{issuer: issuer, audience: audience, now: time.Now}
+	for _, k := range set.Keys {
+		key, err := parseJWK(k)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %v", k.Kid, err)
+		}
+		a.keys = append(a.keys, key)
+	}
+	if len(a.keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s contains no keys", path)
+	}
+	return a, nil
+}
+
+func parseJWK(k jwk) (verificationKey, error) {
+	switch k.Kty {
+	case "oct":
+		if k.Alg != "" && k.Alg != "HS256" {
+			return verificationKey{}, fmt.Errorf("unsupported alg %s for oct key", k.Alg)
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return verificationKey{}, fmt.Errorf("invalid k")
+		}
+		return verificationKey{kid: k.Kid, alg: "HS256", secret: secret}, nil
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return verificationKey{}, fmt.Errorf("unsupported alg %s for RSA key", k.Alg)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return verificationKey{}, fmt.Errorf("invalid n")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return verificationKey{}, fmt.Errorf("invalid e")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return verificationKey{kid: k.Kid, alg: "RS256", rsa: pub}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
+
+func (a *JWTAuthenticator) Method() string {
+	return MethodJWT
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidCredentials)
+	}
+
+	p := &Principal{
+		Subject: subject,
+		Method:  MethodJWT,
+		Roles:   stringList(claims["roles"]),
+		Claims:  claims,
+	}
+	if scope, ok := claims["scope"].(string); ok {
+		p.Scopes = strings.Fields(scope)
+	}
+	return p, nil
+}
+
+// verify checks the signature and the time, issuer and audience claims and
+// returns the decoded claim set.
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding")
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	if !a.verifySignature(header.Alg, header.Kid, signingInput, sig) {
+		return nil, fmt.Errorf("signature verification failed")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %v", err)
+	}
+
+	now := a.now()
+	if exp, ok := numericClaim(claims["exp"]); !ok {
+		return nil, fmt.Errorf("missing exp claim")
+	} else if now.After(time.Unix(exp, 0).Add(jwtLeeway)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if nbf, ok := numericClaim(claims["nbf"]); ok && now.Add(jwtLeeway).Before(time.Unix(nbf, 0)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+	if a.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.issuer {
+			return nil, fmt.Errorf("unexpected issuer")
+		}
+	}
+	if a.audience != "" && !containsString(stringList(claims["aud"]), a.audience) {
+		return nil, fmt.Errorf("unexpected audience")
+	}
+
+	return claims, nil
+}
+
+func (a *JWTAuthenticator) verifySignature(alg, kid string, input, sig []byte) bool {
+	digest := sha256.Sum256(input)
+	for _, key := range a.keys {
+		if key.alg != alg || (kid != "" && key.kid != kid) {
+			continue
+		}
+		switch alg {
+		case "HS256":
+			mac := hmac.New(sha256.New, key.secret)
+			mac.Write(input)
+			if hmac.Equal(mac.Sum(nil), sig) {
+				return true
+			}
+		case "RS256":
+			if rsa.VerifyPKCS1v15(key.rsa, crypto.SHA256, digest[:], sig) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func numericClaim(v interface{}) (int64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, true
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// stringList accepts either a single string or an array of strings, the two
+// shapes used for aud and role-like claims.
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
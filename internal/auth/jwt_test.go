@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// writeJWKS writes a JWKS holding an HS256 secret and an RSA public key to a
+// temp file and returns its path.
+func writeJWKS(t *testing.T, secret []byte, pub *rsa.PublicKey) string {
+	t.Helper()
+	set := jwks{Keys: []jwk{
+		{Kid: "hmac", Kty: "oct", Alg: "HS256", K: b64.EncodeToString(secret)},
+		{Kid: "rsa", Kty: "RSA", Alg: "RS256", N: b64.EncodeToString(pub.N.Bytes()), E: b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())},
+	}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func signToken(t *testing.T, alg, kid string, claims map[string]interface{}, hmacKey []byte, rsaKey *rsa.PrivateKey) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, hmacKey)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(input))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewJWTAuthenticator(writeJWKS(t, secret, &rsaKey.PublicKey), "https://issuer.example", "bookstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	a.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "alice",
+			"iss":   "https://issuer.example",
+			"aud":   []string{"bookstore"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"roles": []string{"editor"},
+			"scope": "books:read books:write",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"hs256", signToken(t, "HS256", "hmac", claims(nil), secret, nil), false},
+		{"rs256", signToken(t, "RS256", "rsa", claims(nil), nil, rsaKey), false},
+		{"rs256 without kid", signToken(t, "RS256", "", claims(nil), nil, rsaKey), false},
+		{"single audience string", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"aud": "bookstore"}), secret, nil), false},
+		{"within leeway", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"exp": now.Add(-10 * time.Second).Unix()}), secret, nil), false},
+		{"expired", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()}), secret, nil), true},
+		{"missing exp", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"exp": nil}), secret, nil), true},
+		{"not yet valid", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()}), secret, nil), true},
+		{"wrong audience", signToken(t, "RS256", "rsa", claims(map[string]interface{}{"aud": "someone-else"}), nil, rsaKey), true},
+		{"wrong issuer", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"iss": "https://evil.example"}), secret, nil), true},
+		{"missing sub", signToken(t, "HS256", "hmac", claims(map[string]interface{}{"sub": nil}), secret, nil), true},
+		{"wrong hmac secret", signToken(t, "HS256", "hmac", claims(nil), []byte("not-the-secret-not-the-secret!!"), nil), true},
+		{"unknown rsa key", signToken(t, "RS256", "rsa", claims(nil), nil, otherKey), true},
+		{"alg none", b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + b64.EncodeToString([]byte(`{"sub":"alice"}`)) + ".", true},
+		{"malformed", "not-a-token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			p, err := a.Authenticate(r)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("want ErrInvalidCredentials, got principal %+v, err %v", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Subject != "alice" || p.Method != MethodJWT {
+				t.Errorf("principal = %+v", p)
+			}
+			if len(p.Roles) != 1 || p.Roles[0] != "editor" {
+				t.Errorf("roles = %v", p.Roles)
+			}
+			if len(p.Scopes) != 2 || p.Scopes[1] != "books:write" {
+				t.Errorf("scopes = %v", p.Scopes)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorNoCredentials(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewJWTAuthenticator(writeJWKS(t, []byte("secret"), &rsaKey.PublicKey), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, header := range []string{"", "Basic dXNlcjpwYXNz", "ApiKey abc"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		if _, err := a.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("Authorization %q: want ErrNoCredentials, got %v", header, err)
+		}
+	}
+}
+
+func TestNewJWTAuthenticatorRejectsBadJWKS(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty":       `{"keys": []}`,
+		"unknown kty": `{"keys": [{"kty": "EC", "kid": "ec"}]}`,
+		"wrong alg":   `{"keys": [{"kty": "oct", "alg": "HS512", "k": "c2VjcmV0"}]}`,
+		"not json":    `keys`,
+	} {
+		path := filepath.Join(dir, "jwks.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewJWTAuthenticator(path, "", ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
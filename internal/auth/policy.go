@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	RoleReader = "reader"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Rule grants access to one route and method. A caller is allowed if it holds
+// any of Roles or any of Scopes. When Query is set the rule only applies if
+// that query parameter is true, which lets a request such as
+// GET /books?include_deleted=true require more than a plain GET /books.
+type Rule struct {
+	Method string
+	Route  string
+	Query  string
+	Roles  []string
+	Scopes []string
+}
+
+func (r Rule) Allows(roles, scopes []string) bool {
+	for _, role := range roles {
+		if containsString(r.Roles, role) {
+			return true
+		}
+	}
+	for _, scope := range scopes {
+		if containsString(r.Scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is a declarative table of Rules keyed by method and route pattern
+// (the gin FullPath, e.g. "/api/v1/books/:id").
+type Policy struct {
+	rules map[string][]Rule
+	roles map[string]bool
+}
+
+func NewPolicy(rules ...Rule) *Policy {
+	p := &Policy{rules: make(map[string][]Rule), roles: make(map[string]bool)}
+	for _, rule := range rules {
+		for _, role := range rule.Roles {
+			p.roles[role] = true
+		}
+		key := rule.Method + " " + rule.Route
+		// Query-qualified rules are checked before the unqualified one.
+		if rule.Query != "" {
+			p.rules[key] = append([]Rule{rule}, p.rules[key]...)
+		} else {
+			p.rules[key] = append(p.rules[key], rule)
+		}
+	}
+	return p
+}
+
+// Match returns the rule for the request, if the policy has one.
+func (p *Policy) Match(r *http.Request, route string) (Rule, bool) {
+	return p.MatchRoute(r.Method, route, r.URL.Query())
+}
+
+// MatchRoute is Match for callers that have no *http.Request for the route,
+// such as GraphQL resolvers mapping an operation onto the equivalent REST
+// route.
+func (p *Policy) MatchRoute(method, route string, query url.Values) (Rule, bool) {
+	for _, rule := range p.rules[method+" "+route] {
+		if rule.Query == "" {
+			return rule, true
+		}
+		if v, err := strconv.ParseBool(query.Get(rule.Query)); err == nil && v {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// KnownRole reports whether any rule mentions role.
+func (p *Policy) KnownRole(role string) bool {
+	return p.roles[role]
+}
+
+// RoleSource says where a caller's roles come from: a request header set by a
+// trusted gateway, or a claim on the authenticated principal.
+type RoleSource struct {
+	Header string
+	Claim  string
+}
+
+// Roles resolves the caller's roles. With a claim source, principals without
+// that claim (such as API keys) fall back to their configured roles.
+func (s RoleSource) Roles(r *http.Request, p *Principal) []string {
+	if s.Header != "" {
+		var roles []string
+		for _, role := range strings.Split(r.Header.Get(s.Header), ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	}
+
+	if p == nil {
+		return nil
+	}
+	if s.Claim != "" {
+		if v, ok := p.Claims[s.Claim]; ok {
+			return stringList(v)
+		}
+	}
+	return p.Roles
+}
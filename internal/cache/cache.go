@@ -0,0 +1,14 @@
+// Package cache provides the Cache abstraction used by the read-through book
+// cache, with an in-process LRU implementation. Values are opaque bytes so
+// that external caches can implement the same interface.
+package cache
+
+import "context"
+
+// Cache stores byte values by key. Get reports stale=true for entries whose
+// TTL has passed but which are still held, so callers can fall back to them.
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, stale bool, ok bool)
+	Set(ctx context.Context, key string, value []byte)
+	Delete(ctx context.Context, key string)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheEntries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_entries",
+			Help: "Number of entries currently held by the cache",
+		},
+		[]string{"cache"},
+	)
+
+	cacheEvictionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_evictions_total",
+			Help: "Total number of entries evicted to make room in the cache",
+		},
+		[]string{"cache"},
+	)
+)
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRU is an in-process cache bounded by entry count. Entries expire after the
+// TTL but stay available as stale values until they are evicted.
+type LRU struct {
+	name     string
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewLRU creates a cache holding at most capacity entries. name labels the
+// cache's metrics.
+func NewLRU(name string, capacity int, ttl time.Duration) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU{
+		name:     name,
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*lruEntry)
+	return entry.value, time.Now().After(entry.expires), true
+}
+
+func (c *LRU) Set(_ context.Context, key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+		cacheEvictionsTotal.WithLabelValues(c.name).Inc()
+	}
+	cacheEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
+
+func (c *LRU) Delete(_ context.Context, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		cacheEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+	}
+}
@@ -0,0 +1,165 @@
+// Package events fans book change notifications out to live subscribers and
+// keeps a short history so that clients can resume after a reconnect.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/reqctx"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+var (
+	eventsPublishedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "book_events_published_total",
+			Help: "Total number of book change events published by type",
+		},
+		[]string{"type"},
+	)
+
+	eventSubscribers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "book_event_subscribers",
+			Help: "Number of clients currently subscribed to book change events",
+		},
+	)
+
+	eventsDroppedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "book_events_dropped_total",
+			Help: "Total number of events not delivered because a subscriber fell behind",
+		},
+	)
+)
+
+// Event is one change to the catalog. Book is the state after the change and
+// is nil for deletions.
+type Event struct {
+	ID     uint64       `json:"id"`
+	Type   string       `json:"type"`
+	BookID int          `json:"book_id"`
+	Book   *models.Book `json:"book,omitempty"`
+	Actor  string       `json:"actor,omitempty"`
+	Time   time.Time    `json:"time"`
+}
+
+// Bus is an in-process publish/subscribe hub. Event IDs increase by one per
+// event and restart when the process does. A nil *Bus discards everything,
+// so publishers need not check whether streaming is enabled.
+type Bus struct {
+	mu         sync.Mutex
+	nextID     uint64
+	history    []Event // ring buffer, oldest at start
+	start      int
+	bufferSize int
+	subs       map[*Subscription]struct{}
+}
+
+// NewBus keeps the last history events for resuming, and lets each
+// subscriber fall up to bufferSize events behind before it is dropped.
+func NewBus(history, bufferSize int) *Bus {
+	return &Bus{
+		nextID:     1,
+		history:    make([]Event, 0, max(history, 1)),
+		bufferSize: max(bufferSize, 1),
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records a change made on behalf of the request in ctx.
+func (b *Bus) Publish(ctx context.Context, typ string, bookID int, book *models.Book) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := Event{ID: b.nextID, Type: typ, BookID: bookID, Book: book, Actor: reqctx.Actor(ctx), Time: time.Now().UTC()}
+	b.nextID++
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.start] = e
+		b.start = (b.start + 1) % len(b.history)
+	}
+	eventsPublishedTotal.WithLabelValues(typ).Inc()
+
+	for s := range b.subs {
+		select {
+		case s.ch <- e:
+		default:
+			// A subscriber that cannot keep up is cut off rather than
+			// allowed to hold back the others; it can resume from the
+			// history with the last ID it saw.
+			eventsDroppedTotal.Inc()
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe starts a subscription. With resume set, the events after lastID
+// that are still in the history are returned for replay; complete is false
+// when some of them have already been discarded.
+func (b *Bus) Subscribe(lastID uint64, resume bool) (s *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if resume {
+		for i := range b.history {
+			e := b.history[(b.start+i)%len(b.history)]
+			if e.ID > lastID {
+				replay = append(replay, e)
+			}
+		}
+		oldest := b.nextID
+		if len(b.history) > 0 {
+			oldest = b.history[b.start].ID
+		}
+		complete = lastID+1 >= oldest && lastID < b.nextID
+	}
+
+	s = &Subscription{bus: b, ch: make(chan Event, b.bufferSize)}
+	b.subs[s] = struct{}{}
+	eventSubscribers.Inc()
+	return s, replay, complete
+}
+
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+	eventSubscribers.Dec()
+}
+
+// Subscription delivers events published after it was created. Its channel
+// is closed when the subscription is closed or dropped for falling behind.
+type Subscription struct {
+	bus *Bus
+	ch  chan Event
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryMillis is the reconnection delay suggested to EventSource clients.
+const retryMillis = 3000
+
+// Handler streams bus events as Server-Sent Events. A client resumes by
+// sending the last ID it saw in the Last-Event-ID header (browsers do this on
+// reconnect) or the last_event_id query parameter. If the events it missed
+// are no longer in the history, a "reset" event tells it to reload the
+// catalog before relying on the stream. A comment line is sent every
+// heartbeat so that idle proxies keep the connection open.
+func Handler(bus *Bus, heartbeat time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.GetHeader("Last-Event-ID")
+		if value == "" {
+			value = c.Query("last_event_id")
+		}
+		var lastID uint64
+		resume := value != ""
+		if resume {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+				return
+			}
+			lastID = id
+		}
+
+		sub, replay, complete := bus.Subscribe(lastID, resume)
+		defer sub.Close()
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		// Stop nginx and similar proxies from buffering the stream
+		header.Set("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		w := c.Writer
+		fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+		if !complete {
+			io.WriteString(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, e := range replay {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		w.Flush()
+
+		var tick <-chan time.Time
+		if heartbeat > 0 {
+			ticker := time.NewTicker(heartbeat)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case e, ok := <-sub.Events():
+				if !ok {
+					log.Printf("Event stream subscriber fell behind, closing stream")
+					return
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			case <-tick:
+				if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			w.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
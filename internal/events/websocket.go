@@ -0,0 +1,335 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxMessageSize bounds a single client message; subscription requests are
+// small.
+const maxMessageSize = 8 << 10
+
+var (
+	websocketConnections = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_connections",
+			Help: "Number of open WebSocket connections",
+		},
+	)
+
+	websocketMessagesSentTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_messages_sent_total",
+			Help: "Total number of WebSocket messages sent by message type",
+		},
+		[]string{"type"},
+	)
+
+	websocketDisconnectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_disconnects_total",
+			Help: "Total number of closed WebSocket connections by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+// WebSocketConfig configures WebSocketHandler.
+type WebSocketConfig struct {
+	// PingInterval is how often the server pings; a client that has not
+	// answered within PongTimeout is disconnected.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	// WriteTimeout bounds a single write. A client that does not read fast
+	// enough for it is disconnected as a slow consumer.
+	WriteTimeout time.Duration
+	// MaxSubscriptions caps the book IDs plus authors one connection may
+	// watch.
+	MaxSubscriptions int
+	// AllowedOrigins lists the browser origins that may connect, or "*" for
+	// any. When empty only same-origin pages may connect.
+	AllowedOrigins []string
+}
+
+var DefaultWebSocketConfig = WebSocketConfig{
+	PingInterval:     30 * time.Second,
+	PongTimeout:      60 * time.Second,
+	WriteTimeout:     10 * time.Second,
+	MaxSubscriptions: 100,
+}
+
+// clientMessage is sent by clients to change what they watch. Subscribing
+// adds to the current filter and unsubscribing removes from it.
+type clientMessage struct {
+	Type    string   `json:"type"`
+	BookIDs []int    `json:"book_ids"`
+	Authors []string `json:"authors"`
+}
+
+// serverMessage is sent to clients: the current filter after each change
+// ("subscribed"), a matching change ("event") or a rejected request ("error").
+type serverMessage struct {
+	Type          string         `json:"type"`
+	Subscriptions *subscriptions `json:"subscriptions,omitempty"`
+	Event         *Event         `json:"event,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+type subscriptions struct {
+	BookIDs []int    `json:"book_ids"`
+	Authors []string `json:"authors"`
+}
+
+// filter is what one connection watches. Books matched by author are
+// remembered so that their deletion, which carries no book, still matches,
+// and so that the change moving a book to another author is delivered as the
+// last event for that book.
+type filter struct {
+	ids     map[int]bool
+	authors map[string]bool
+	matched map[int]bool
+}
+
+func (f *filter) matches(e Event) bool {
+	if f.ids[e.BookID] {
+		return true
+	}
+	if e.Book != nil && f.authors[strings.ToLower(e.Book.Author)] {
+		f.matched[e.BookID] = true
+		return true
+	}
+	if f.matched[e.BookID] {
+		// A book here no longer has a watched author
+		if e.Type == Deleted || e.Book != nil {
+			delete(f.matched, e.BookID)
+		}
+		return true
+	}
+	return false
+}
+
+func (f *filter) apply(m clientMessage, limit int) error {
+	switch m.Type {
+	case "subscribe":
+		if len(f.ids)+len(f.authors)+len(m.BookIDs)+len(m.Authors) > limit {
+			return errors.New("too many subscriptions")
+		}
+		for _, id := range m.BookIDs {
+			f.ids[id] = true
+		}
+		for _, author := range m.Authors {
+			if author = strings.TrimSpace(author); author != "" {
+				f.authors[strings.ToLower(author)] = true
+			}
+		}
+	case "unsubscribe":
+		for _, id := range m.BookIDs {
+			delete(f.ids, id)
+		}
+		for _, author := range m.Authors {
+			delete(f.authors, strings.ToLower(strings.TrimSpace(author)))
+		}
+		// Forget author matches; books still watched are matched again on
+		// their next change
+		clear(f.matched)
+	default:
+		return errors.New(`type must be "subscribe" or "unsubscribe"`)
+	}
+	return nil
+}
+
+func (f *filter) state() serverMessage {
+	subs := &subscriptions{BookIDs: []int{}, Authors: []string{}}
+	for id := range f.ids {
+		subs.BookIDs = append(subs.BookIDs, id)
+	}
+	for author := range f.authors {
+		subs.Authors = append(subs.Authors, author)
+	}
+	slices.Sort(subs.BookIDs)
+	slices.Sort(subs.Authors)
+	return serverMessage{Type: "subscribed", Subscriptions: subs}
+}
+
+// WebSocketHandler upgrades the request to a WebSocket over which the client
+// subscribes to changes of specific books or of books by specific authors
+// (case-insensitive) and receives each matching event as it happens. A new
+// connection watches nothing until it subscribes.
+func WebSocketHandler(bus *Bus, cfg WebSocketConfig) gin.HandlerFunc {
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin(cfg.AllowedOrigins)}
+
+	return func(c *gin.Context) {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// The upgrader has already replied with an error status
+			log.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+		websocketConnections.Inc()
+		defer websocketConnections.Dec()
+
+		reason := serveWebSocket(conn, bus, cfg)
+		websocketDisconnectsTotal.WithLabelValues(reason).Inc()
+		log.Printf("WebSocket connection from %s closed: %s", c.ClientIP(), reason)
+	}
+}
+
+// serveWebSocket runs one connection until it ends and returns why it ended.
+// Only this goroutine writes to conn; a second one reads client messages and
+// hands them over on requests.
+func serveWebSocket(conn *websocket.Conn, bus *Bus, cfg WebSocketConfig) string {
+	defer conn.Close()
+
+	sub, _, _ := bus.Subscribe(0, false)
+	defer sub.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	requests := make(chan clientRequest)
+	readErr := make(chan string, 1)
+	go func() {
+		readErr <- readMessages(conn, cfg.PongTimeout, requests, done)
+	}()
+
+	write := func(m serverMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+		if err := conn.WriteJSON(m); err != nil {
+			return err
+		}
+		websocketMessagesSentTotal.WithLabelValues(m.Type).Inc()
+		return nil
+	}
+
+	ping := time.NewTicker(cfg.PingInterval)
+	defer ping.Stop()
+
+	f := &filter{ids: map[int]bool{}, authors: map[string]bool{}, matched: map[int]bool{}}
+	for {
+		select {
+		case reason := <-readErr:
+			return reason
+
+		case req := <-requests:
+			err := req.err
+			if err == nil {
+				err = f.apply(req.msg, cfg.MaxSubscriptions)
+			}
+			reply := f.state()
+			if err != nil {
+				reply = serverMessage{Type: "error", Error: err.Error()}
+			}
+			if err := write(reply); err != nil {
+				return writeFailure(err)
+			}
+
+		case e, ok := <-sub.Events():
+			if !ok {
+				// The bus dropped us for not draining events in time
+				msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer")
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+				return "slow_consumer"
+			}
+			if !f.matches(e) {
+				continue
+			}
+			if err := write(serverMessage{Type: "event", Event: &e}); err != nil {
+				return writeFailure(err)
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(cfg.WriteTimeout)); err != nil {
+				return writeFailure(err)
+			}
+			websocketMessagesSentTotal.WithLabelValues("ping").Inc()
+		}
+	}
+}
+
+// writeFailure classifies a failed write. A write that times out means the
+// client is not reading fast enough.
+func writeFailure(err error) string {
+	if isTimeout(err) {
+		return "slow_consumer"
+	}
+	return "write_error"
+}
+
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+type clientRequest struct {
+	msg clientMessage
+	err error
+}
+
+// readMessages reads client messages until the connection fails or done is
+// closed and returns the disconnect reason. Any message, including a pong,
+// extends the read deadline.
+func readMessages(conn *websocket.Conn, pongTimeout time.Duration, requests chan<- clientRequest, done <-chan struct{}) string {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			switch {
+			case errors.As(err, &closeErr), errors.Is(err, net.ErrClosed):
+				return "client_closed"
+			case isTimeout(err):
+				return "pong_timeout"
+			case errors.Is(err, websocket.ErrReadLimit):
+				return "message_too_large"
+			default:
+				return "read_error"
+			}
+		}
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+
+		var req clientRequest
+		if kind != websocket.TextMessage {
+			req.err = errors.New("messages must be JSON text")
+		} else if err := json.Unmarshal(data, &req.msg); err != nil {
+			req.err = errors.New("invalid JSON message")
+		}
+		select {
+		case requests <- req:
+		case <-done:
+			return ""
+		}
+	}
+}
+
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil // same-origin only
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(allowed, "*") {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return slices.Contains(allowed, u.Scheme+"://"+u.Host)
+	}
+}
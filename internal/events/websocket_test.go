@@ -0,0 +1,31 @@
+package events
+
+import (
+	"testing"
+
+	"gin-prometheus-grafana/internal/models"
+)
+
+func TestFilterForgetsBooksThatChangeAuthor(t *testing.T) {
+	f := &filter{ids: map[int]bool{}, authors: map[string]bool{"frank herbert": true}, matched: map[int]bool{}}
+	herbert := &models.Book{ID: 1, Author: "Frank Herbert"}
+	other := &models.Book{ID: 1, Author: "Brian Herbert"}
+
+	tests := []struct {
+		name string
+		e    Event
+		want bool
+	}{
+		{"watched author", Event{Type: Created, BookID: 1, Book: herbert}, true},
+		{"moved to another author", Event{Type: Updated, BookID: 1, Book: other}, true},
+		{"updated under the other author", Event{Type: Updated, BookID: 1, Book: other}, false},
+		{"deleted under the other author", Event{Type: Deleted, BookID: 1}, false},
+		{"moved back", Event{Type: Updated, BookID: 1, Book: herbert}, true},
+		{"deleted", Event{Type: Deleted, BookID: 1}, true},
+	}
+	for _, tt := range tests {
+		if got := f.matches(tt.e); got != tt.want {
+			t.Errorf("%s: matches = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
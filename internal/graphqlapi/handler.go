@@ -0,0 +1,191 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	graphqlOperationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "graphql_operations_total",
+			Help: "Total number of GraphQL operations by top-level field, type and result",
+		},
+		[]string{"operation", "type", "result"},
+	)
+
+	graphqlOperationDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "graphql_operation_duration_seconds",
+			Help: "Duration of GraphQL operations in seconds by top-level field and type",
+		},
+		[]string{"operation", "type"},
+	)
+)
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler executes GraphQL requests sent as a JSON POST body or, for queries
+// only, as GET query parameters. caller, when set, supplies the roles and
+// scopes that the resolvers authorize against.
+func Handler(schema graphql.Schema, limits Limits, caller func(c *gin.Context) Caller) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if v := c.Query("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variables"})
+					return
+				}
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GraphQL request"})
+			return
+		}
+		if req.Query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing query"})
+			return
+		}
+
+		start := time.Now()
+		doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+		if err != nil {
+			graphqlOperationsTotal.WithLabelValues("other", "unknown", "invalid").Inc()
+			c.JSON(http.StatusBadRequest, graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}})
+			return
+		}
+
+		op := selectOperation(doc, req.OperationName)
+		if op == nil {
+			graphqlOperationsTotal.WithLabelValues("other", "unknown", "invalid").Inc()
+			c.JSON(http.StatusBadRequest, errorResult("Unknown operation or operationName required"))
+			return
+		}
+		labels := prometheus.Labels{"operation": operationLabel(&schema, op), "type": op.Operation}
+
+		if c.Request.Method == http.MethodGet && op.Operation != ast.OperationTypeQuery {
+			graphqlOperationsTotal.With(withResult(labels, "invalid")).Inc()
+			c.Header("Allow", "POST")
+			c.JSON(http.StatusMethodNotAllowed, errorResult("Only queries may be sent with GET"))
+			return
+		}
+
+		if res := graphql.ValidateDocument(&schema, doc, nil); !res.IsValid {
+			graphqlOperationsTotal.With(withResult(labels, "invalid")).Inc()
+			c.JSON(http.StatusBadRequest, graphql.Result{Errors: res.Errors})
+			return
+		}
+		if err := checkLimits(doc, op, req.Variables, limits); err != nil {
+			graphqlOperationsTotal.With(withResult(labels, "rejected")).Inc()
+			c.JSON(http.StatusBadRequest, errorResult(err.Error()))
+			return
+		}
+
+		ctx := c.Request.Context()
+		if caller != nil {
+			ctx = withCaller(ctx, caller(c))
+		}
+		result := graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           doc,
+			OperationName: req.OperationName,
+			Args:          req.Variables,
+			Context:       ctx,
+		})
+
+		outcome := "success"
+		if result.HasErrors() {
+			outcome = "error"
+		}
+		graphqlOperationsTotal.With(withResult(labels, outcome)).Inc()
+		graphqlOperationDuration.With(labels).Observe(time.Since(start).Seconds())
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// selectOperation returns the operation named name, or the only operation in
+// doc when name is empty.
+func selectOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil
+			}
+			found = op
+		} else if op.Name != nil && op.Name.Value == name {
+			return op
+		}
+	}
+	return found
+}
+
+// operationLabel names an operation for metrics by the schema field it
+// selects, such as "books" or "createBook", rather than by the client-chosen
+// operation name, so the label only takes a fixed set of values. Operations
+// selecting several fields are "multiple", introspection is "introspection",
+// and anything else, including fields the schema lacks, is "other".
+func operationLabel(schema *graphql.Schema, op *ast.OperationDefinition) string {
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeQuery:
+		root = schema.QueryType()
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	}
+	if root == nil || op.SelectionSet == nil {
+		return "other"
+	}
+
+	label := ""
+	for _, selection := range op.SelectionSet.Selections {
+		field, ok := selection.(*ast.Field)
+		if !ok || field.Name == nil {
+			return "other"
+		}
+		name := field.Name.Value
+		switch {
+		case strings.HasPrefix(name, "__"):
+			name = "introspection"
+		case root.Fields()[name] == nil:
+			return "other"
+		}
+		if label != "" && label != name {
+			return "multiple"
+		}
+		label = name
+	}
+	if label == "" {
+		return "other"
+	}
+	return label
+}
+
+func withResult(labels prometheus.Labels, result string) prometheus.Labels {
+	return prometheus.Labels{"operation": labels["operation"], "type": labels["type"], "result": result}
+}
+
+func errorResult(message string) graphql.Result {
+	return graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(message)}}
+}
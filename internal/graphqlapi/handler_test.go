@@ -0,0 +1,37 @@
+package graphqlapi
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestOperationLabel(t *testing.T) {
+	schema, err := NewSchema(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`query AnyNameTheClientLikes { books(first: 1) { edges { cursor } } }`, "books"},
+		{`{ a: book(id: 1) { id } b: book(id: 2) { id } }`, "book"},
+		{`mutation { deleteBook(id: 1) }`, "deleteBook"},
+		{`{ book(id: 1) { id } books { pageInfo { hasNextPage } } }`, "multiple"},
+		{`{ __schema { types { name } } }`, "introspection"},
+		{`{ noSuchField }`, "other"},
+		{`{ ...Fields } fragment Fields on Query { book(id: 1) { id } }`, "other"},
+		{`subscription { bookChanged { id } }`, "other"},
+	}
+	for _, tt := range tests {
+		doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := operationLabel(&schema, selectOperation(doc, "")); got != tt.want {
+			t.Errorf("%s: label = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bound how expensive a single operation may be. Depth counts nested
+// fields; complexity counts every field that may be resolved, multiplying the
+// fields under a list by its page size, so books(first: 100) { edges { node {
+// title } } } costs far more than book(id: 1) { title }. Zero disables a limit.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// pagedFields are the fields whose selections are resolved once per item, with
+// their page size when the query does not set "first".
+var pagedFields = map[string]int{
+	"books": defaultPageSize,
+}
+
+type limitWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+// checkLimits measures op, whose fragments are looked up in doc, against l.
+func checkLimits(doc *ast.Document, op *ast.OperationDefinition, variables map[string]interface{}, l Limits) error {
+	w := &limitWalker{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			w.fragments[frag.Name.Value] = frag
+		}
+	}
+
+	depth, complexity := w.walk(op.SelectionSet, 1)
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth, l.MaxDepth)
+	}
+	if l.MaxComplexity > 0 && complexity > l.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, l.MaxComplexity)
+	}
+	return nil
+}
+
+// walk returns the depth and complexity of set when each of its fields is
+// resolved multiplier times.
+func (w *limitWalker) walk(set *ast.SelectionSet, multiplier int) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, sel := range set.Selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = w.walk(sel.SelectionSet, multiplier*w.pageSize(sel))
+			d++
+			c += multiplier
+		case *ast.InlineFragment:
+			d, c = w.walk(sel.SelectionSet, multiplier)
+		case *ast.FragmentSpread:
+			frag := w.fragments[sel.Name.Value]
+			// Validation rejects cycles already; this only guards the walk.
+			if frag == nil || w.visiting[frag.Name.Value] {
+				continue
+			}
+			w.visiting[frag.Name.Value] = true
+			d, c = w.walk(frag.SelectionSet, multiplier)
+			delete(w.visiting, frag.Name.Value)
+		}
+		depth = max(depth, d)
+		complexity += c
+	}
+	return depth, complexity
+}
+
+func (w *limitWalker) pageSize(f *ast.Field) int {
+	size, paged := pagedFields[f.Name.Value]
+	if !paged {
+		return 1
+	}
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				size = n
+			}
+		case *ast.Variable:
+			if n, ok := w.variables[v.Name.Value].(float64); ok {
+				size = int(n)
+			}
+		}
+	}
+	return max(min(size, maxPageSize), 1)
+}
@@ -0,0 +1,412 @@
+// Package graphqlapi serves the book catalog over GraphQL at /graphql, backed
+// by the same repository as the REST API.
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-prometheus-grafana/internal/auth"
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/repository"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errForbidden = errors.New("insufficient permissions")
+
+// Caller is who is running an operation, for authorization in resolvers.
+type Caller struct {
+	Roles  []string
+	Scopes []string
+}
+
+type callerKey struct{}
+
+func withCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// resolver holds what the field resolvers need. Each one authorizes itself
+// against the REST route with the same meaning, so GraphQL cannot do more than
+// the REST API allows.
+type resolver struct {
+	repo   repository.BookStore
+	policy *auth.Policy
+}
+
+func (r *resolver) authorize(ctx context.Context, method, route string, query url.Values) error {
+	if r.policy == nil {
+		return nil
+	}
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	rule, ok := r.policy.MatchRoute(method, route, query)
+	if !ok || !rule.Allows(caller.Roles, caller.Scopes) {
+		return errForbidden
+	}
+	return nil
+}
+
+// NewSchema builds the GraphQL schema. A nil policy disables authorization.
+func NewSchema(repo repository.BookStore, policy *auth.Policy) (graphql.Schema, error) {
+	r := &resolver{repo: repo, policy: policy}
+
+	money := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Money",
+		Description: "A decimal amount in an ISO 4217 currency",
+		Fields: graphql.Fields{
+			"amount":   &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(models.Money).Decimal(), nil }},
+			"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(models.Money).Currency, nil }},
+		},
+	})
+
+	bookField := func(t graphql.Output, get func(*models.Book) interface{}) *graphql.Field {
+		return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*models.Book)), nil
+		}}
+	}
+	book := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Book",
+		Fields: graphql.Fields{
+			"id":          bookField(graphql.NewNonNull(graphql.ID), func(b *models.Book) interface{} { return strconv.Itoa(b.ID) }),
+			"title":       bookField(graphql.NewNonNull(graphql.String), func(b *models.Book) interface{} { return b.Title }),
+			"author":      bookField(graphql.NewNonNull(graphql.String), func(b *models.Book) interface{} { return b.Author }),
+			"isbn":        bookField(graphql.NewNonNull(graphql.String), func(b *models.Book) interface{} { return b.ISBN }),
+			"price":       bookField(graphql.NewNonNull(money), func(b *models.Book) interface{} { return b.Price }),
+			"publishedAt": bookField(graphql.NewNonNull(graphql.DateTime), func(b *models.Book) interface{} { return b.PublishedAt }),
+			"createdAt":   bookField(graphql.NewNonNull(graphql.DateTime), func(b *models.Book) interface{} { return b.CreatedAt }),
+			"updatedAt":   bookField(graphql.NewNonNull(graphql.DateTime), func(b *models.Book) interface{} { return b.UpdatedAt }),
+			"deletedAt": bookField(graphql.DateTime, func(b *models.Book) interface{} {
+				if b.DeletedAt == nil {
+					return nil
+				}
+				return *b.DeletedAt
+			}),
+		},
+	})
+
+	edge := graphql.NewObject(graphql.ObjectConfig{
+		Name: "BookEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return encodeCursor(p.Source.(*models.Book).ID), nil
+			}},
+			"node": &graphql.Field{Type: graphql.NewNonNull(book), Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }},
+		},
+	})
+	pageInfo := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"startCursor":     &graphql.Field{Type: graphql.String},
+			"endCursor":       &graphql.Field{Type: graphql.String},
+		},
+	})
+	connection := graphql.NewObject(graphql.ObjectConfig{
+		Name: "BookConnection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edge)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfo)},
+		},
+	})
+
+	filter := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "BookFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":          &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case-insensitive substring of the title"},
+			"author":         &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case-insensitive substring of the author"},
+			"isbn":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"includeDeleted": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		},
+	})
+	moneyInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MoneyInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"amount":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"currency": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Defaults to " + models.DefaultCurrency},
+		},
+	})
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateBookInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"author":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"isbn":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"price":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(moneyInput)},
+			"publishedAt": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateBookInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"author":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"isbn":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"price":       &graphql.InputObjectFieldConfig{Type: moneyInput},
+			"publishedAt": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"book": &graphql.Field{
+				Type:    book,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.book,
+			},
+			"books": &graphql.Field{
+				Type: graphql.NewNonNull(connection),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: filter},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, Description: "Page size, at most 100", DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String, Description: "Cursor of the last edge of the previous page"},
+				},
+				Resolve: r.books,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createBook": &graphql.Field{
+				Type:    graphql.NewNonNull(book),
+				Args:    graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createInput)}},
+				Resolve: r.createBook,
+			},
+			"updateBook": &graphql.Field{
+				Type: graphql.NewNonNull(book),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: r.updateBook,
+			},
+			"deleteBook": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Soft-deletes a book and returns its ID",
+				Args:        graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     r.deleteBook,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func (r *resolver) book(p graphql.ResolveParams) (interface{}, error) {
+	if err := r.authorize(p.Context, http.MethodGet, "/api/v1/books/:id", nil); err != nil {
+		return nil, err
+	}
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	book, err := r.repo.GetBookByID(p.Context, id)
+	if err != nil {
+		// Like GET /books/{id}, a missing book is not an error
+		log.Printf("GraphQL: failed to get book by ID %d: %v", id, err)
+		return nil, nil
+	}
+	return book, nil
+}
+
+func (r *resolver) books(p graphql.ResolveParams) (interface{}, error) {
+	var filter models.BookFilter
+	if f, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.Title, _ = f["title"].(string)
+		filter.Author, _ = f["author"].(string)
+		filter.ISBN, _ = f["isbn"].(string)
+		filter.IncludeDeleted, _ = f["includeDeleted"].(bool)
+	}
+
+	query := url.Values{}
+	if filter.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	if err := r.authorize(p.Context, http.MethodGet, "/api/v1/books", query); err != nil {
+		return nil, err
+	}
+
+	first, _ := p.Args["first"].(int)
+	if first <= 0 || first > maxPageSize {
+		return nil, errors.New("first must be between 1 and 100")
+	}
+	afterID := 0
+	if after, ok := p.Args["after"].(string); ok && after != "" {
+		id, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		afterID = id
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	books, err := r.repo.SearchBooks(p.Context, filter, afterID, first+1)
+	if err != nil {
+		log.Printf("GraphQL: failed to search books: %v", err)
+		return nil, errors.New("failed to retrieve books")
+	}
+	hasNext := len(books) > first
+	if hasNext {
+		books = books[:first]
+	}
+
+	edges := make([]*models.Book, len(books))
+	for i := range books {
+		edges[i] = &books[i]
+	}
+	pageInfo := map[string]interface{}{"hasNextPage": hasNext, "hasPreviousPage": afterID > 0}
+	if len(books) > 0 {
+		pageInfo["startCursor"] = encodeCursor(books[0].ID)
+		pageInfo["endCursor"] = encodeCursor(books[len(books)-1].ID)
+	}
+	return map[string]interface{}{"edges": edges, "pageInfo": pageInfo}, nil
+}
+
+func (r *resolver) createBook(p graphql.ResolveParams) (interface{}, error) {
+	if err := r.authorize(p.Context, http.MethodPost, "/api/v1/books", nil); err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]interface{})
+
+	req := &models.CreateBookRequest{
+		Title:  input["title"].(string),
+		Author: input["author"].(string),
+		ISBN:   input["isbn"].(string),
+	}
+	if t, ok := input["publishedAt"].(time.Time); ok {
+		req.PublishedAt = t
+	}
+	price, err := parseMoneyInput(input["price"])
+	if err != nil {
+		return nil, err
+	}
+	req.Price = price
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return nil, err
+	}
+
+	book, err := r.repo.CreateBook(p.Context, req)
+	if errors.Is(err, repository.ErrDuplicateISBN) {
+		return nil, repository.ErrDuplicateISBN
+	}
+	if err != nil {
+		log.Printf("GraphQL: failed to create book: %v", err)
+		return nil, errors.New("failed to create book")
+	}
+	return book, nil
+}
+
+func (r *resolver) updateBook(p graphql.ResolveParams) (interface{}, error) {
+	if err := r.authorize(p.Context, http.MethodPut, "/api/v1/books/:id", nil); err != nil {
+		return nil, err
+	}
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]interface{})
+
+	req := &models.UpdateBookRequest{}
+	if v, ok := input["title"].(string); ok {
+		req.Title = &v
+	}
+	if v, ok := input["author"].(string); ok {
+		req.Author = &v
+	}
+	if v, ok := input["isbn"].(string); ok {
+		req.ISBN = &v
+	}
+	if v, ok := input["publishedAt"].(time.Time); ok {
+		req.PublishedAt = &v
+	}
+	if v, ok := input["price"]; ok && v != nil {
+		price, err := parseMoneyInput(v)
+		if err != nil {
+			return nil, err
+		}
+		req.Price = &price
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return nil, err
+	}
+
+	book, err := r.repo.UpdateBook(p.Context, id, req)
+	if errors.Is(err, repository.ErrDuplicateISBN) {
+		return nil, repository.ErrDuplicateISBN
+	}
+	if err != nil {
+		log.Printf("GraphQL: failed to update book ID %d: %v", id, err)
+		return nil, errors.New("book not found")
+	}
+	return book, nil
+}
+
+func (r *resolver) deleteBook(p graphql.ResolveParams) (interface{}, error) {
+	if err := r.authorize(p.Context, http.MethodDelete, "/api/v1/books/:id", nil); err != nil {
+		return nil, err
+	}
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repo.DeleteBook(p.Context, id); err != nil {
+		log.Printf("GraphQL: failed to delete book ID %d: %v", id, err)
+		return nil, errors.New("book not found")
+	}
+	return strconv.Itoa(id), nil
+}
+
+func parseID(v interface{}) (int, error) {
+	s, _ := v.(string)
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("invalid book ID")
+	}
+	return id, nil
+}
+
+func parseMoneyInput(v interface{}) (models.Money, error) {
+	m, _ := v.(map[string]interface{})
+	amount, _ := m["amount"].(string)
+	currency, _ := m["currency"].(string)
+	price, err := models.ParseMoney(amount, currency)
+	if err != nil {
+		return models.Money{}, fmt.Errorf("invalid price: %v", err)
+	}
+	return price, nil
+}
+
+// Cursors are opaque to clients; they encode the ID of the edge's book.
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("book:" + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if s, ok := strings.CutPrefix(string(raw), "book:"); ok {
+			if id, err := strconv.Atoi(s); err == nil {
+				return id, nil
+			}
+		}
+	}
+	return 0, errors.New("invalid cursor")
+}
@@ -0,0 +1,184 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"gin-prometheus-grafana/internal/auth"
+	bookstorev1 "gin-prometheus-grafana/internal/pb/bookstore/v1"
+	"gin-prometheus-grafana/internal/reqctx"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Access configures authentication and authorization for the gRPC server
+// with the same authenticators and policy as the REST API. With no
+// Authenticators calls are anonymous, and with a nil Policy every caller may
+// call every method.
+type Access struct {
+	Authenticators []auth.Authenticator
+	Policy         *auth.Policy
+	RoleSource     auth.RoleSource
+}
+
+// restRoute is the REST route a gRPC method corresponds to, so the policy
+// written for the REST API decides for gRPC too. query adds the query
+// parameters that qualify a rule, such as include_deleted.
+type restRoute struct {
+	method string
+	route  string
+	query  func(req interface{}) url.Values
+}
+
+var methodRoutes = map[string]restRoute{
+	bookstorev1.BookService_CreateBook_FullMethodName: {method: http.MethodPost, route: "/api/v1/books"},
+	bookstorev1.BookService_GetBook_FullMethodName:    {method: http.MethodGet, route: "/api/v1/books/:id"},
+	bookstorev1.BookService_ListBooks_FullMethodName: {method: http.MethodGet, route: "/api/v1/books", query: func(req interface{}) url.Values {
+		if r, ok := req.(*bookstorev1.ListBooksRequest); ok && r.GetIncludeDeleted() {
+			return url.Values{"include_deleted": {"true"}}
+		}
+		return nil
+	}},
+	bookstorev1.BookService_UpdateBook_FullMethodName: {method: http.MethodPut, route: "/api/v1/books/:id"},
+	bookstorev1.BookService_DeleteBook_FullMethodName: {method: http.MethodDelete, route: "/api/v1/books/:id"},
+}
+
+// caller is the authenticated identity of a call along with the HTTP view of
+// its metadata that authenticators and role sources read.
+type caller struct {
+	request   *http.Request
+	principal *auth.Principal
+}
+
+// authenticate runs the authenticators against the call's metadata and, on
+// success, makes the principal the actor recorded in the audit log.
+func (a Access) authenticate(ctx context.Context) (context.Context, *caller, error) {
+	c := &caller{request: httpRequest(ctx)}
+	if len(a.Authenticators) == 0 {
+		return ctx, c, nil
+	}
+
+	for _, authenticator := range a.Authenticators {
+		principal, err := authenticator.Authenticate(c.request)
+		if errors.Is(err, auth.ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			log.Printf("gRPC: authentication failed using %s: %v", authenticator.Method(), err)
+			return ctx, nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		c.principal = principal
+		return reqctx.WithActor(ctx, principal.Subject), c, nil
+	}
+	return ctx, nil, status.Error(codes.Unauthenticated, "authentication required")
+}
+
+// authorize checks the call against the policy rule for its REST route.
+// Methods without a route, such as server reflection, are denied when a
+// policy is set, as routes without a rule are over HTTP.
+func (a Access) authorize(fullMethod string, c *caller, req interface{}) error {
+	if a.Policy == nil {
+		return nil
+	}
+
+	var scopes []string
+	if c.principal != nil {
+		scopes = c.principal.Scopes
+	}
+	roles := a.RoleSource.Roles(c.request, c.principal)
+
+	route, ok := methodRoutes[fullMethod]
+	if ok {
+		var query url.Values
+		if route.query != nil {
+			query = route.query(req)
+		}
+		if rule, ok := a.Policy.MatchRoute(route.method, route.route, query); ok && rule.Allows(roles, scopes) {
+			return nil
+		}
+	}
+	log.Printf("gRPC: denied %s for roles %v", fullMethod, roles)
+	return status.Error(codes.PermissionDenied, "insufficient permissions")
+}
+
+func (a Access) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, c, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authorize(info.FullMethod, c, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a Access) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, c, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	if _, ok := methodRoutes[info.FullMethod]; !ok {
+		if err := a.authorize(info.FullMethod, c, nil); err != nil {
+			return err
+		}
+	}
+	return handler(srv, &authorizedStream{
+		ServerStream: ss,
+		ctx:          ctx,
+		authorize:    func(req interface{}) error { return a.authorize(info.FullMethod, c, req) },
+	})
+}
+
+// authorizedStream defers authorization to the first received message, since
+// for ListBooks the rule depends on the request's include_deleted flag.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx        context.Context
+	authorize  func(req interface{}) error
+	authorized bool
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if !s.authorized {
+		if err := s.authorize(m); err != nil {
+			return err
+		}
+		s.authorized = true
+	}
+	return nil
+}
+
+// httpRequest presents the call's metadata as HTTP headers and its verified
+// TLS state as the request's, which is what auth.Authenticator and
+// auth.RoleSource read.
+func httpRequest(ctx context.Context) *http.Request {
+	r := &http.Request{Method: http.MethodPost, URL: &url.URL{}, Header: http.Header{}}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, v := range values {
+			r.Header.Add(key, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := info.State
+			r.TLS = &state
+		}
+	}
+	return r.WithContext(ctx)
+}
@@ -0,0 +1,233 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"gin-prometheus-grafana/internal/auth"
+	"gin-prometheus-grafana/internal/handlers"
+	"gin-prometheus-grafana/internal/models"
+	bookstorev1 "gin-prometheus-grafana/internal/pb/bookstore/v1"
+	"gin-prometheus-grafana/internal/repository"
+	"gin-prometheus-grafana/internal/reqctx"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeStore serves a single book and records the actor of each write.
+type fakeStore struct {
+	repository.BookStore
+
+	mu     sync.Mutex
+	actors []string
+}
+
+func (s *fakeStore) record(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actors = append(s.actors, reqctx.Actor(ctx))
+}
+
+func (s *fakeStore) lastActor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.actors) == 0 {
+		return ""
+	}
+	return s.actors[len(s.actors)-1]
+}
+
+func (s *fakeStore) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	return &models.Book{ID: id, Title: "Dune"}, nil
+}
+
+func (s *fakeStore) GetAllBooks(ctx context.Context, includeDeleted bool) ([]models.Book, error) {
+	return []models.Book{{ID: 1, Title: "Dune"}}, nil
+}
+
+func (s *fakeStore) StreamBooks(ctx context.Context, fn func(*models.Book) error) error {
+	return fn(&models.Book{ID: 1, Title: "Dune"})
+}
+
+func (s *fakeStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	s.record(ctx)
+	return &models.Book{ID: id, Title: *req.Title}, nil
+}
+
+func (s *fakeStore) DeleteBook(ctx context.Context, id int) error {
+	s.record(ctx)
+	return nil
+}
+
+var testPolicy = auth.NewPolicy(
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books", Query: "include_deleted", Roles: []string{auth.RoleAdmin}, Scopes: []string{"books:admin"}},
+	auth.Rule{Method: http.MethodGet, Route: "/api/v1/books/:id", Roles: []string{auth.RoleReader, auth.RoleEditor, auth.RoleAdmin}},
+	auth.Rule{Method: http.MethodPut, Route: "/api/v1/books/:id", Roles: []string{auth.RoleEditor, auth.RoleAdmin}},
+	auth.Rule{Method: http.MethodDelete, Route: "/api/v1/books/:id", Roles: []string{auth.RoleAdmin}},
+)
+
+// dialTestServer serves NewServer over an in-memory listener.
+func dialTestServer(t *testing.T, store repository.BookStore, access Access) *grpc.ClientConn {
+	t.Helper()
+	if err := handlers.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(store, access)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withKey(key string) context.Context {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "mallory")
+	if key != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+	}
+	return ctx
+}
+
+func listBooks(ctx context.Context, client bookstorev1.BookServiceClient, includeDeleted bool) error {
+	stream, err := client.ListBooks(ctx, &bookstorev1.ListBooksRequest{IncludeDeleted: includeDeleted})
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	authenticator, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{ID: "reader", Hash: auth.HashAPIKey("reader-secret"), Roles: []string{auth.RoleReader}},
+		{ID: "editor", Hash: auth.HashAPIKey("editor-secret"), Roles: []string{auth.RoleEditor}},
+		{ID: "admin", Hash: auth.HashAPIKey("admin-secret"), Roles: []string{auth.RoleAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{}
+	client := bookstorev1.NewBookServiceClient(dialTestServer(t, store, Access{
+		Authenticators: []auth.Authenticator{authenticator},
+		Policy:         testPolicy,
+		RoleSource:     auth.RoleSource{Claim: "roles"},
+	}))
+
+	title := "Dune Messiah"
+	tests := []struct {
+		name string
+		key  string
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{"get without credentials", "", func(ctx context.Context) error {
+			_, err := client.GetBook(ctx, &bookstorev1.GetBookRequest{Id: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"list without credentials", "", func(ctx context.Context) error {
+			return listBooks(ctx, client, false)
+		}, codes.Unauthenticated},
+		{"delete with unknown key", "guessed", func(ctx context.Context) error {
+			_, err := client.DeleteBook(ctx, &bookstorev1.DeleteBookRequest{Id: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"reader gets", "reader-secret", func(ctx context.Context) error {
+			_, err := client.GetBook(ctx, &bookstorev1.GetBookRequest{Id: 1})
+			return err
+		}, codes.OK},
+		{"reader lists", "reader-secret", func(ctx context.Context) error {
+			return listBooks(ctx, client, false)
+		}, codes.OK},
+		{"reader lists deleted", "reader-secret", func(ctx context.Context) error {
+			return listBooks(ctx, client, true)
+		}, codes.PermissionDenied},
+		{"reader updates", "reader-secret", func(ctx context.Context) error {
+			_, err := client.UpdateBook(ctx, &bookstorev1.UpdateBookRequest{Id: 1, Title: &title})
+			return err
+		}, codes.PermissionDenied},
+		{"editor updates", "editor-secret", func(ctx context.Context) error {
+			_, err := client.UpdateBook(ctx, &bookstorev1.UpdateBookRequest{Id: 1, Title: &title})
+			return err
+		}, codes.OK},
+		{"editor deletes", "editor-secret", func(ctx context.Context) error {
+			_, err := client.DeleteBook(ctx, &bookstorev1.DeleteBookRequest{Id: 1})
+			return err
+		}, codes.PermissionDenied},
+		{"admin deletes", "admin-secret", func(ctx context.Context) error {
+			_, err := client.DeleteBook(ctx, &bookstorev1.DeleteBookRequest{Id: 1})
+			return err
+		}, codes.OK},
+		{"admin lists deleted", "admin-secret", func(ctx context.Context) error {
+			return listBooks(ctx, client, true)
+		}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call(withKey(tt.key))); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Writes are attributed to the verified principal, not to x-actor
+	want := []string{"apikey:editor", "apikey:admin"}
+	if len(store.actors) != len(want) || store.actors[0] != want[0] || store.actors[1] != want[1] {
+		t.Errorf("actors = %v, want %v", store.actors, want)
+	}
+}
+
+func TestAccessControlDisabled(t *testing.T) {
+	store := &fakeStore{}
+	client := bookstorev1.NewBookServiceClient(dialTestServer(t, store, Access{}))
+
+	if _, err := client.DeleteBook(withKey(""), &bookstorev1.DeleteBookRequest{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.lastActor(); got != "mallory" {
+		t.Errorf("actor = %q, want the x-actor metadata", got)
+	}
+}
+
+func TestAccessControlDeniesUnmappedMethods(t *testing.T) {
+	conn := dialTestServer(t, &fakeStore{}, Access{Policy: testPolicy, RoleSource: auth.RoleSource{Header: "x-roles"}})
+
+	// Roles from a gateway header reach the policy, but server reflection has
+	// no REST route and so no rule
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-roles", auth.RoleAdmin)
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo")
+	if err == nil {
+		err = stream.RecvMsg(new(bookstorev1.Book))
+	}
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Errorf("reflection code = %v, want %v", got, codes.PermissionDenied)
+	}
+
+	if _, err := bookstorev1.NewBookServiceClient(conn).DeleteBook(ctx, &bookstorev1.DeleteBookRequest{Id: 1}); err != nil {
+		t.Errorf("admin delete: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Package grpcserver serves the book catalog over gRPC, sharing the
+// repository and validation rules with the REST handlers.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"gin-prometheus-grafana/internal/models"
+	bookstorev1 "gin-prometheus-grafana/internal/pb/bookstore/v1"
+	"gin-prometheus-grafana/internal/repository"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewServer returns a gRPC server with BookService, server reflection and
+// the metrics, panic recovery, request-context and access interceptors registered. The caller decides
+// which listener to serve it on.
+func NewServer(repo repository.BookStore, access Access, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryMetricsInterceptor, unaryRecoveryInterceptor, unaryContextInterceptor, access.unaryInterceptor),
+		grpc.ChainStreamInterceptor(streamMetricsInterceptor, streamRecoveryInterceptor, streamContextInterceptor, access.streamInterceptor),
+	}, opts...)
+
+	srv := grpc.NewServer(opts...)
+	bookstorev1.RegisterBookServiceServer(srv, NewBookService(repo))
+	reflection.Register(srv)
+	return srv
+}
+
+// BookService implements bookstorev1.BookServiceServer.
+type BookService struct {
+	bookstorev1.UnimplementedBookServiceServer
+	repo repository.BookStore
+}
+
+func NewBookService(repo repository.BookStore) *BookService {
+	return &BookService{repo: repo}
+}
+
+func (s *BookService) CreateBook(ctx context.Context, req *bookstorev1.CreateBookRequest) (*bookstorev1.Book, error) {
+	createReq := &models.CreateBookRequest{
+		Title:  req.GetTitle(),
+		Author: req.GetAuthor(),
+		ISBN:   req.GetIsbn(),
+	}
+	if req.GetPublishedAt() != nil {
+		createReq.PublishedAt = req.GetPublishedAt().AsTime()
+	}
+	if req.GetPrice() != nil {
+		price, err := fromProtoMoney(req.GetPrice())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid price: %v", err)
+		}
+		createReq.Price = price
+	}
+	if err := binding.Validator.ValidateStruct(createReq); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	book, err := s.repo.CreateBook(ctx, createReq)
+	if errors.Is(err, repository.ErrDuplicateISBN) {
+		return nil, status.Error(codes.AlreadyExists, "a book with this ISBN already exists")
+	}
+	if err != nil {
+		log.Printf("gRPC: failed to create book: %v", err)
+		return nil, repoError(ctx, codes.Internal, "failed to create book")
+	}
+	return toProtoBook(book), nil
+}
+
+func (s *BookService) GetBook(ctx context.Context, req *bookstorev1.GetBookRequest) (*bookstorev1.Book, error) {
+	book, err := s.repo.GetBookByID(ctx, int(req.GetId()))
+	if err != nil {
+		log.Printf("gRPC: failed to get book by ID %d: %v", req.GetId(), err)
+		return nil, repoError(ctx, codes.NotFound, "book not found")
+	}
+	return toProtoBook(book), nil
+}
+
+func (s *BookService) ListBooks(req *bookstorev1.ListBooksRequest, stream bookstorev1.BookService_ListBooksServer) error {
+	ctx := stream.Context()
+	send := func(book *models.Book) error {
+		return stream.Send(toProtoBook(book))
+	}
+
+	if req.GetIncludeDeleted() {
+		books, err := s.repo.GetAllBooks(ctx, true)
+		if err != nil {
+			log.Printf("gRPC: failed to list books: %v", err)
+			return repoError(ctx, codes.Internal, "failed to list books")
+		}
+		for i := range books {
+			if err := send(&books[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := s.repo.StreamBooks(ctx, send); err != nil {
+		log.Printf("gRPC: failed to stream books: %v", err)
+		return repoError(ctx, codes.Internal, "failed to list books")
+	}
+	return nil
+}
+
+func (s *BookService) UpdateBook(ctx context.Context, req *bookstorev1.UpdateBookRequest) (*bookstorev1.Book, error) {
+	updateReq := &models.UpdateBookRequest{
+		Title:  req.Title,
+		Author: req.Author,
+		ISBN:   req.Isbn,
+	}
+	if req.GetPublishedAt() != nil {
+		publishedAt := req.GetPublishedAt().AsTime()
+		updateReq.PublishedAt = &publishedAt
+	}
+	if req.GetPrice() != nil {
+		price, err := fromProtoMoney(req.GetPrice())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid price: %v", err)
+		}
+		updateReq.Price = &price
+	}
+	if err := binding.Validator.ValidateStruct(updateReq); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	book, err := s.repo.UpdateBook(ctx, int(req.GetId()), updateReq)
+	if errors.Is(err, repository.ErrDuplicateISBN) {
+		return nil, status.Error(codes.AlreadyExists, "a book with this ISBN already exists")
+	}
+	if err != nil {
+		log.Printf("gRPC: failed to update book ID %d: %v", req.GetId(), err)
+		return nil, repoError(ctx, codes.NotFound, "book not found")
+	}
+	return toProtoBook(book), nil
+}
+
+func (s *BookService) DeleteBook(ctx context.Context, req *bookstorev1.DeleteBookRequest) (*bookstorev1.DeleteBookResponse, error) {
+	if err := s.repo.DeleteBook(ctx, int(req.GetId())); err != nil {
+		log.Printf("gRPC: failed to delete book ID %d: %v", req.GetId(), err)
+		return nil, repoError(ctx, codes.NotFound, "book not found")
+	}
+	return &bookstorev1.DeleteBookResponse{}, nil
+}
+
+// repoError reports a failed repository call with code, unless it failed
+// because the client's deadline passed or it cancelled the call.
+func repoError(ctx context.Context, code codes.Code, msg string) error {
+	if err := ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return status.Error(codes.DeadlineExceeded, err.Error())
+		}
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return status.Error(code, msg)
+}
+
+func fromProtoMoney(m *bookstorev1.Money) (models.Money, error) {
+	currency := m.GetCurrency()
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	return models.ParseMoney(m.GetAmount(), currency)
+}
+
+func toProtoBook(b *models.Book) *bookstorev1.Book {
+	pb := &bookstorev1.Book{
+		Id:          int32(b.ID),
+		Title:       b.Title,
+		Author:      b.Author,
+		Isbn:        b.ISBN,
+		Price:       &bookstorev1.Money{Amount: b.Price.Decimal(), Currency: b.Price.Currency},
+		PublishedAt: timestamppb.New(b.PublishedAt),
+		CreatedAt:   timestamppb.New(b.CreatedAt),
+		UpdatedAt:   timestamppb.New(b.UpdatedAt),
+	}
+	if b.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*b.DeletedAt)
+	}
+	return pb
+}
@@ -0,0 +1,51 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gin-prometheus-grafana/internal/models"
+	bookstorev1 "gin-prometheus-grafana/internal/pb/bookstore/v1"
+	"gin-prometheus-grafana/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// takenISBNStore fails every write with a duplicate ISBN.
+type takenISBNStore struct {
+	repository.BookStore
+}
+
+func (takenISBNStore) CreateBook(ctx context.Context, req *models.CreateBookRequest) (*models.Book, error) {
+	return nil, fmt.Errorf("isbn %s: %w", req.ISBN, repository.ErrDuplicateISBN)
+}
+
+func (takenISBNStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	return nil, fmt.Errorf("isbn %s: %w", *req.ISBN, repository.ErrDuplicateISBN)
+}
+
+func TestDuplicateISBNAlreadyExists(t *testing.T) {
+	client := bookstorev1.NewBookServiceClient(dialTestServer(t, takenISBNStore{}, Access{}))
+	ctx := context.Background()
+
+	_, err := client.CreateBook(ctx, &bookstorev1.CreateBookRequest{
+		Title:       "Dune",
+		Author:      "Frank Herbert",
+		Isbn:        "0441172717",
+		Price:       &bookstorev1.Money{Amount: "9.99", Currency: "USD"},
+		PublishedAt: timestamppb.New(time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)),
+	})
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("CreateBook code = %v, want %v (%v)", got, codes.AlreadyExists, err)
+	}
+
+	isbn := "9780441172719"
+	_, err = client.UpdateBook(ctx, &bookstorev1.UpdateBookRequest{Id: 1, Isbn: &isbn})
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("UpdateBook code = %v, want %v (%v)", got, codes.AlreadyExists, err)
+	}
+}
@@ -0,0 +1,134 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"gin-prometheus-grafana/internal/reqctx"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_requests_total",
+			Help: "Total number of gRPC requests",
+		},
+		[]string{"method", "code"},
+	)
+
+	grpcRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "grpc_request_duration_seconds",
+			Help: "Duration of gRPC requests in seconds",
+		},
+		[]string{"method", "code"},
+	)
+
+	grpcRequestsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "grpc_requests_in_flight",
+			Help: "Number of gRPC requests currently being processed",
+		},
+	)
+)
+
+// observe records a finished call the same way PrometheusMiddleware records
+// an HTTP request, with the status code name in place of the HTTP status.
+func observe(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	grpcRequestsTotal.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
+
+func unaryMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	grpcRequestsInFlight.Inc()
+	defer grpcRequestsInFlight.Dec()
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observe(info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamMetricsInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	grpcRequestsInFlight.Inc()
+	defer grpcRequestsInFlight.Dec()
+
+	start := time.Now()
+	err := handler(srv, ss)
+	observe(info.FullMethod, start, err)
+	return err
+}
+
+// withRequestContext takes the request ID and actor from the x-request-id and
+// x-actor metadata, like the RequestID and Actor HTTP middleware, so gRPC
+// changes show up in the audit log the same way. With authentication enabled
+// the access interceptor replaces the actor with the verified principal.
+func withRequestContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	id := first("x-request-id")
+	if id == "" || len(id) > 64 {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	actor := first("x-actor")
+	if actor == "" {
+		actor = "anonymous"
+	}
+	return reqctx.WithActor(reqctx.WithRequestID(ctx, id), actor)
+}
+
+func unaryContextInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestContext(ctx), req)
+}
+
+func streamContextInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: withRequestContext(ss.Context())})
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// recovered turns a panic in a handler into an Internal error instead of
+// crashing the process.
+func recovered(method string, err *error) {
+	if rec := recover(); rec != nil {
+		log.Printf("gRPC: panic recovered in %s: %v\n%s", method, rec, debug.Stack())
+		*err = status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func unaryRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer recovered(info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+func streamRecoveryInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recovered(info.FullMethod, &err)
+	return handler(srv, ss)
+}
@@ -1,20 +1,23 @@
 package handlers
 
 import (
+	"errors"
+	"gin-prometheus-grafana/internal/isbn"
 	"gin-prometheus-grafana/internal/models"
 	"gin-prometheus-grafana/internal/repository"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type BookHandler struct {
-	repo *repository.BookRepository
+	repo repository.BookStore
 }
 
-func NewBookHandler(repo *repository.BookRepository) *BookHandler {
+func NewBookHandler(repo repository.BookStore) *BookHandler {
 	return &BookHandler{repo: repo}
 }
 
@@ -26,7 +29,12 @@ func (h *BookHandler) CreateBook(c *gin.Context) {
 		return
 	}
 
-	book, err := h.repo.CreateBook(&req)
+	book, err := h.repo.CreateBook(c.Request.Context(), &req)
+	if errors.Is(err, repository.ErrDuplicateISBN) {
+		log.Printf("Failed to create book: %v", err)
+		c.JSON(http.StatusConflict, gin.H{"error": "A book with this ISBN already exists"})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to create book: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create book"})
@@ -46,7 +54,7 @@ func (h *BookHandler) GetBookByID(c *gin.Context) {
 		return
 	}
 
-	book, err := h.repo.GetBookByID(id)
+	book, err := h.repo.GetBookByID(c.Request.Context(), id)
 	if err != nil {
 		log.Printf("Failed to get book by ID %d: %v", id, err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
@@ -57,14 +65,55 @@ func (h *BookHandler) GetBookByID(c *gin.Context) {
 	c.JSON(http.StatusOK, book)
 }
 
+func (h *BookHandler) GetBookByISBN(c *gin.Context) {
+	value := c.Param("isbn")
+	if !isbn.Valid(value) {
+		log.Printf("Invalid ISBN: %s", value)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ISBN"})
+		return
+	}
+
+	book, err := h.repo.GetBookByISBN(c.Request.Context(), value)
+	if err != nil {
+		log.Printf("Failed to get book by ISBN %s: %v", value, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	log.Printf("Successfully retrieved book: %+v", book)
+	c.JSON(http.StatusOK, book)
+}
+
 func (h *BookHandler) GetAllBooks(c *gin.Context) {
-	books, err := h.repo.GetAllBooks()
+	includeDeleted, _ := strconv.ParseBool(c.Query("include_deleted"))
+
+	// Read before the list, so a write in between can only make Last-Modified
+	// older than the list and cost the client a full response
+	lastModified, err := h.repo.GetBooksLastModified(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to get books last modified time: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve books"})
+		return
+	}
+	books, err := h.repo.GetAllBooks(c.Request.Context(), includeDeleted)
 	if err != nil {
 		log.Printf("Failed to get all books: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve books"})
 		return
 	}
 
+	etag := listETag(books)
+	if includeDeleted {
+		// Purges remove soft-deleted rows without leaving a timestamp behind,
+		// so only the ETag can tell whether this list changed
+		lastModified = time.Time{}
+	}
+	if notModified(c, etag, lastModified) {
+		log.Printf("Book list not modified (%d books)", len(books))
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	log.Printf("Successfully retrieved %d books", len(books))
 	c.JSON(http.StatusOK, books)
 }
@@ -85,7 +134,12 @@ func (h *BookHandler) UpdateBook(c *gin.Context) {
 		return
 	}
 
-	book, err := h.repo.UpdateBook(id, &req)
+	book, err := h.repo.UpdateBook(c.Request.Context(), id, &req)
+	if errors.Is(err, repository.ErrDuplicateISBN) {
+		log.Printf("Failed to update book ID %d: %v", id, err)
+		c.JSON(http.StatusConflict, gin.H{"error": "A book with this ISBN already exists"})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to update book ID %d: %v", id, err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
@@ -105,7 +159,7 @@ func (h *BookHandler) DeleteBook(c *gin.Context) {
 		return
 	}
 
-	err = h.repo.DeleteBook(id)
+	err = h.repo.DeleteBook(c.Request.Context(), id)
 	if err != nil {
 		log.Printf("Failed to delete book ID %d: %v", id, err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
@@ -114,4 +168,44 @@ func (h *BookHandler) DeleteBook(c *gin.Context) {
 
 	log.Printf("Successfully deleted book ID %d", id)
 	c.JSON(http.StatusNoContent, nil)
-}
\ No newline at end of file
+}
+
+func (h *BookHandler) RestoreBook(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Printf("Invalid book ID: %s", idStr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	book, err := h.repo.RestoreBook(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to restore book ID %d: %v", id, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted book not found"})
+		return
+	}
+
+	log.Printf("Successfully restored book: %+v", book)
+	c.JSON(http.StatusOK, book)
+}
+
+func (h *BookHandler) GetBookHistory(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Printf("Invalid book ID: %s", idStr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	history, err := h.repo.GetBookHistory(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to get history for book ID %d: %v", id, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book history not found"})
+		return
+	}
+
+	log.Printf("Successfully retrieved %d history entries for book ID %d", len(history.Entries), id)
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// takenISBNStore fails every write with a duplicate ISBN.
+type takenISBNStore struct {
+	repository.BookStore
+}
+
+func (takenISBNStore) CreateBook(ctx context.Context, req *models.CreateBookRequest) (*models.Book, error) {
+	return nil, fmt.Errorf("isbn %s: %w", req.ISBN, repository.ErrDuplicateISBN)
+}
+
+func (takenISBNStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	return nil, fmt.Errorf("isbn %s: %w", *req.ISBN, repository.ErrDuplicateISBN)
+}
+
+func TestDuplicateISBNConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+	h := NewBookHandler(takenISBNStore{})
+	r := gin.New()
+	r.POST("/api/v1/books", h.CreateBook)
+	r.PUT("/api/v1/books/:id", h.UpdateBook)
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/api/v1/books", `{"title":"Dune","author":"Frank Herbert","isbn":"0441172717","price":9.99,"published_at":"1965-08-01T00:00:00Z"}`},
+		{http.MethodPut, "/api/v1/books/1", `{"isbn":"9780441172719"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s %s: status = %d, want %d (body %s)", tt.method, tt.path, w.Code, http.StatusConflict, w.Body)
+		}
+	}
+}
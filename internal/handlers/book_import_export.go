@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-prometheus-grafana/internal/models"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	// exportFlushEvery controls how many rows are written before the response
+	// is flushed to the client.
+	exportFlushEvery = 100
+)
+
+var exportColumns = []string{"id", "title", "author", "isbn", "price", "currency", "published_at", "created_at", "updated_at"}
+
+var importColumns = []string{"title", "author", "isbn", "price", "published_at"}
+
+func (h *BookHandler) ExportBooks(c *gin.Context) {
+	format := c.DefaultQuery("format", formatCSV)
+
+	var writeRow func(*models.Book) error
+	var flush func() error
+
+	switch format {
+	case formatCSV:
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(exportColumns); err != nil {
+			log.Printf("Failed to write CSV header: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export books"})
+			return
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writeRow = func(b *models.Book) error {
+			return w.Write([]string{
+				strconv.Itoa(b.ID),
+				b.Title,
+				b.Author,
+				b.ISBN,
+				b.Price.Decimal(),
+				b.Price.Currency,
+				b.PublishedAt.Format(time.RFC3339),
+				b.CreatedAt.Format(time.RFC3339),
+				b.UpdatedAt.Format(time.RFC3339),
+			})
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	case formatNDJSON:
+		enc := json.NewEncoder(c.Writer)
+		c.Header("Content-Type", "application/x-ndjson")
+		writeRow = func(b *models.Book) error {
+			return enc.Encode(b)
+		}
+		flush = func() error { return nil }
+	default:
+		log.Printf("Unsupported export format: %s", format)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, expected csv or ndjson"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="books.%s"`, format))
+	c.Status(http.StatusOK)
+
+	rows := 0
+	err := h.repo.StreamBooks(c.Request.Context(), func(b *models.Book) error {
+		if err := writeRow(b); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		log.Printf("Failed to export books: %v", err)
+		if !c.Writer.Written() {
+			// Nothing was sent yet, so the export's headers can still be
+			// replaced by a JSON error
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export books"})
+			return
+		}
+		// Headers are already on the wire; abort so the client sees a truncated stream.
+		c.Abort()
+		return
+	}
+
+	log.Printf("Successfully exported %d books as %s", rows, format)
+}
+
+func (h *BookHandler) ImportBooks(c *gin.Context) {
+	body, format, err := importSource(c)
+	if err != nil {
+		log.Printf("Invalid import upload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	report := &models.ImportReport{
+		Created:  []models.ImportRowResult{},
+		Updated:  []models.ImportRowResult{},
+		Rejected: []models.ImportRowResult{},
+	}
+
+	importRow := func(line int, req *models.CreateBookRequest) {
+		if err := binding.Validator.ValidateStruct(req); err != nil {
+			report.Rejected = append(report.Rejected, models.ImportRowResult{Line: line, ISBN: req.ISBN, Error: err.Error()})
+			return
+		}
+
+		book, created, err := h.repo.UpsertBookByISBN(c.Request.Context(), req)
+		if err != nil {
+			report.Rejected = append(report.Rejected, models.ImportRowResult{Line: line, ISBN: req.ISBN, Error: "Failed to store book"})
+			return
+		}
+
+		result := models.ImportRowResult{Line: line, ID: book.ID, ISBN: book.ISBN}
+		if created {
+			report.Created = append(report.Created, result)
+		} else {
+			report.Updated = append(report.Updated, result)
+		}
+	}
+	rejectRow := func(line int, err error) {
+		report.Rejected = append(report.Rejected, models.ImportRowResult{Line: line, Error: err.Error()})
+	}
+
+	switch format {
+	case formatCSV:
+		err = readCSVBooks(body, importRow, rejectRow)
+	case formatNDJSON:
+		err = readNDJSONBooks(body, importRow, rejectRow)
+	}
+	if err != nil {
+		log.Printf("Failed to read %s import: %v", format, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+
+	log.Printf("Import finished: %d created, %d updated, %d rejected",
+		len(report.Created), len(report.Updated), len(report.Rejected))
+	c.JSON(http.StatusOK, report)
+}
+
+// importSource returns the uploaded document and its format. Both multipart
+// uploads (field "file") and raw request bodies are accepted; the format comes
+// from the "format" query parameter, the file extension or the content type.
+func importSource(c *gin.Context) (io.ReadCloser, string, error) {
+	format := c.Query("format")
+
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType == "multipart/form-data" {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", errors.New("multipart upload must contain a \"file\" field")
+		}
+		if format == "" {
+			format = formatFromFilename(fh.Filename)
+		}
+		if format == "" {
+			format = formatFromContentType(fh.Header.Get("Content-Type"))
+		}
+		if format != formatCSV && format != formatNDJSON {
+			return nil, "", errors.New("unsupported format, expected csv or ndjson")
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open upload: %v", err)
+		}
+		return f, format, nil
+	}
+
+	if format == "" {
+		format = formatFromContentType(mediaType)
+	}
+	if format != formatCSV && format != formatNDJSON {
+		return nil, "", errors.New("unsupported format, expected csv or ndjson")
+	}
+	return c.Request.Body, format, nil
+}
+
+func formatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return formatCSV
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	}
+	return ""
+}
+
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return formatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return formatNDJSON
+	}
+	return ""
+}
+
+// readCSVBooks parses a CSV document whose first row names the columns. The
+// currency column is optional and defaults to models.DefaultCurrency; extra
+// columns such as id or created_at are ignored so exports can be re-imported.
+func readCSVBooks(r io.Reader, importRow func(int, *models.CreateBookRequest), rejectRow func(int, error)) error {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return errors.New("CSV document is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %v", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("CSV header is missing column %q", name)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				rejectRow(parseErr.StartLine, parseErr.Err)
+				continue
+			}
+			return fmt.Errorf("invalid CSV: %v", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		req, err := parseCSVBook(record, index)
+		if err != nil {
+			rejectRow(line, err)
+			continue
+		}
+		importRow(line, req)
+	}
+}
+
+func parseCSVBook(record []string, index map[string]int) (*models.CreateBookRequest, error) {
+	field := func(name string) string {
+		i, ok := index[name]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	req := &models.CreateBookRequest{
+		Title:  field("title"),
+		Author: field("author"),
+		ISBN:   field("isbn"),
+	}
+
+	if raw := field("price"); raw != "" {
+		price, err := models.ParseMoney(raw, field("currency"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid price: %v", err)
+		}
+		req.Price = price
+	}
+
+	if raw := field("published_at"); raw != "" {
+		publishedAt, err := parseImportTime(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid published_at %q", raw)
+		}
+		req.PublishedAt = publishedAt
+	}
+
+	return req, nil
+}
+
+func parseImportTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// readNDJSONBooks parses one JSON object per line; blank lines are skipped.
+func readNDJSONBooks(r io.Reader, importRow func(int, *models.CreateBookRequest), rejectRow func(int, error)) error {
+	br := bufio.NewReader(r)
+	line := 0
+
+	for {
+		raw, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read NDJSON: %v", err)
+		}
+
+		if len(raw) > 0 {
+			line++
+			if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 {
+				var req models.CreateBookRequest
+				if jsonErr := json.Unmarshal(trimmed, &req); jsonErr != nil {
+					rejectRow(line, fmt.Errorf("invalid JSON: %v", jsonErr))
+				} else {
+					importRow(line, &req)
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingStreamStore fails to stream the catalog before any row is read.
+type failingStreamStore struct {
+	repository.BookStore
+}
+
+func (failingStreamStore) StreamBooks(ctx context.Context, fn func(*models.Book) error) error {
+	return errors.New("connection refused")
+}
+
+func TestExportFailureIsJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/v1/books/export", NewBookHandler(failingStreamStore{}).ExportBooks)
+
+	for _, format := range []string{formatCSV, formatNDJSON} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/books/export?format="+format, nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status = %d, want %d", format, w.Code, http.StatusInternalServerError)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+			t.Errorf("%s: Content-Type = %q, want JSON", format, got)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != "" {
+			t.Errorf("%s: Content-Disposition = %q, want none", format, got)
+		}
+	}
+}
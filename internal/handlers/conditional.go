@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"gin-prometheus-grafana/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// listCacheControl lets clients store the book list but makes them revalidate
+// it with If-None-Match/If-Modified-Since before every reuse.
+const listCacheControl = "private, no-cache"
+
+var httpConditionalResponsesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_conditional_responses_total",
+		Help: "Total number of cacheable responses by path and result (not_modified, full)",
+	},
+	[]string{"path", "result"},
+)
+
+// listETag derives a weak ETag for a book list. It covers every book's ID and
+// updated_at, so additions, updates and deletions all change it. The list's
+// Last-Modified comes from BookStore.GetBooksLastModified instead, since the
+// newest updated_at in the list does not move when a book is deleted.
+func listETag(books []models.Book) string {
+	h := sha256.New()
+	buf := make([]byte, 16)
+	for _, b := range books {
+		binary.BigEndian.PutUint64(buf[:8], uint64(b.ID))
+		binary.BigEndian.PutUint64(buf[8:], uint64(b.UpdatedAt.UnixNano()))
+		h.Write(buf)
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// notModified sets the caching headers and reports whether the request's
+// preconditions show the client already holds the current representation.
+// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.2.2).
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	c.Header("Cache-Control", listCacheControl)
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	result := false
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		result = etagMatches(inm, etag)
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			result = !lastModified.Truncate(time.Second).After(t)
+		}
+	}
+
+	label := "full"
+	if result {
+		label = "not_modified"
+	}
+	httpConditionalResponsesTotal.WithLabelValues(c.FullPath(), label).Inc()
+	return result
+}
+
+// etagMatches applies the weak comparison used for If-None-Match.
+func etagMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"gin-prometheus-grafana/internal/isbn"
+	"gin-prometheus-grafana/internal/models"
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators installs the custom binding tags used by the request
+// models. It must be called before the router starts serving requests.
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+
+	// Money fields are validated on their minor-unit amount, so tags such as
+	// "required,min=0" keep their meaning; currency and scale are checked when
+	// the value is decoded.
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if m, ok := field.Interface().(models.Money); ok {
+			return m.Amount
+		}
+		return nil
+	}, models.Money{})
+
+	// Replaces the stock "isbn" tag, which rejects hyphenated input.
+	return v.RegisterValidation("isbn", func(fl validator.FieldLevel) bool {
+		return isbn.Valid(fl.Field().String())
+	})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/repository"
+	"gin-prometheus-grafana/internal/webhooks"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+type WebhookHandler struct {
+	repo    *repository.WebhookRepository
+	targets webhooks.TargetGuard
+}
+
+// NewWebhookHandler returns a handler that rejects webhook URLs the targets
+// guard forbids.
+func NewWebhookHandler(repo *repository.WebhookRepository, targets webhooks.TargetGuard) *WebhookHandler {
+	return &WebhookHandler{repo: repo, targets: targets}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request body: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.targets.CheckURL(c.Request.Context(), req.URL); err != nil {
+		log.Printf("Rejected webhook URL %s: %v", req.URL, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Secret == "" {
+		secret, err := webhooks.GenerateSecret()
+		if err != nil {
+			log.Printf("Failed to generate webhook secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+		req.Secret = secret
+	}
+
+	webhook, err := h.repo.CreateWebhook(c.Request.Context(), &req)
+	if err != nil {
+		log.Printf("Failed to create webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	// The secret is returned here and never again.
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *WebhookHandler) GetAllWebhooks(c *gin.Context) {
+	webhooks, err := h.repo.ListWebhooks(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to list webhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) GetWebhookByID(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.repo.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Failed to get webhook ID %d: %v", id, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request body: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.URL != nil {
+		if err := h.targets.CheckURL(c.Request.Context(), *req.URL); err != nil {
+			log.Printf("Rejected webhook URL %s: %v", *req.URL, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	webhook, err := h.repo.UpdateWebhook(c.Request.Context(), id, &req)
+	if err != nil {
+		log.Printf("Failed to update webhook ID %d: %v", id, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	log.Printf("Successfully updated webhook ID %d", id)
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteWebhook(c.Request.Context(), id); err != nil {
+		log.Printf("Failed to delete webhook ID %d: %v", id, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	log.Printf("Successfully deleted webhook ID %d", id)
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetDeliveries returns the webhook's delivery log, newest first, optionally
+// filtered by status.
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	limit := defaultDeliveryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	if _, err := h.repo.GetWebhook(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	deliveries, err := h.repo.ListDeliveries(c.Request.Context(), id, status, limit)
+	if err != nil {
+		log.Printf("Failed to list deliveries for webhook ID %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver queues a delivery to be sent again immediately, typically a dead
+// one after the receiver has been fixed.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.repo.RedeliverDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		log.Printf("Failed to redeliver delivery ID %d: %v", deliveryID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	log.Printf("Queued redelivery of delivery ID %d for webhook ID %d", deliveryID, id)
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func webhookID(c *gin.Context) (int64, bool) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		log.Printf("Invalid webhook ID: %s", idStr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,81 @@
+// Package isbn validates ISBN-10 and ISBN-13 identifiers and converts them to
+// the canonical form stored in the books table: 13 digits without separators.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid ISBN")
+
+// Normalize strips hyphens and spaces, verifies the checksum and returns the
+// ISBN-13 form of s. ISBN-10 values are converted using the 978 prefix.
+func Normalize(s string) (string, error) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+
+	switch len(digits) {
+	case 10:
+		if !validISBN10(digits) {
+			return "", ErrInvalid
+		}
+		base := "978" + digits[:9]
+		return base + string(isbn13CheckDigit(base)), nil
+	case 13:
+		if !validISBN13(digits) {
+			return "", ErrInvalid
+		}
+		return digits, nil
+	default:
+		return "", ErrInvalid
+	}
+}
+
+// Valid reports whether s is a well-formed ISBN-10 or ISBN-13.
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+func validISBN10(s string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := s[i]
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c == 'X' && i == 9:
+			v = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * v
+	}
+	return sum%11 == 0
+}
+
+func validISBN13(s string) bool {
+	for i := 0; i < 13; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+		return false
+	}
+	return isbn13CheckDigit(s[:12]) == s[12]
+}
+
+// isbn13CheckDigit computes the check digit for the first 12 digits of an ISBN-13.
+func isbn13CheckDigit(s string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		v := int(s[i] - '0')
+		if i%2 == 1 {
+			v *= 3
+		}
+		sum += v
+	}
+	return byte('0' + (10-sum%10)%10)
+}
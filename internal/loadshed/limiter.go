@@ -0,0 +1,162 @@
+// Package loadshed bounds the number of requests processed concurrently with
+// a limit that adapts to observed latency (AIMD), queueing briefly when the
+// limit is reached and shedding what cannot be admitted in time.
+package loadshed
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("concurrency queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting for a concurrency slot")
+)
+
+type Config struct {
+	// InitialLimit, MinLimit and MaxLimit bound the adaptive limit.
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// TargetLatency is the latency above which a request counts as a sign of
+	// overload and the limit is cut.
+	TargetLatency time.Duration
+	// Backoff is the multiplicative decrease factor, e.g. 0.9.
+	Backoff float64
+	// MaxQueue is how many requests may wait for a slot; QueueTimeout is how
+	// long each of them waits before being shed.
+	MaxQueue     int
+	QueueTimeout time.Duration
+}
+
+// Stats is a snapshot of the limiter state.
+type Stats struct {
+	Limit    int
+	InFlight int
+	Queued   int
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Limiter is an additive-increase/multiplicative-decrease concurrency limiter.
+// Each request below the target latency raises the limit by 1/limit, so the
+// limit grows by about one per window of successful requests; each request
+// above it multiplies the limit by Backoff.
+type Limiter struct {
+	cfg Config
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	queue    *list.List
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.MinLimit < 1 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit < cfg.MinLimit || cfg.InitialLimit > cfg.MaxLimit {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.9
+	}
+	return &Limiter{cfg: cfg, limit: float64(cfg.InitialLimit), queue: list.New()}
+}
+
+// Acquire takes a slot, waiting up to QueueTimeout if none is free. Every
+// successful Acquire must be paired with a Release.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inFlight < l.currentLimit() && l.queue.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.queue.Len() >= l.cfg.MaxQueue {
+		l.mu.Unlock()
+		return ErrQueueFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	elem := l.queue.PushBack(w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// A slot was handed over while we were giving up; keep it.
+		return nil
+	}
+	l.queue.Remove(elem)
+	return err
+}
+
+// Release frees a slot and feeds the request's latency into the limit.
+// overloaded forces a decrease regardless of latency, e.g. for timeouts.
+func (l *Limiter) Release(latency time.Duration, overloaded bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if overloaded || latency > l.cfg.TargetLatency {
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+	} else {
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	}
+
+	l.admitQueued()
+}
+
+// admitQueued hands free slots to queued requests. The caller holds mu.
+func (l *Limiter) admitQueued() {
+	for l.queue.Len() > 0 && l.inFlight < l.currentLimit() {
+		w := l.queue.Remove(l.queue.Front()).(*waiter)
+		w.granted = true
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// Abandon frees a slot without feeding the request into the limit, for
+// requests whose latency says nothing about the server, such as ones the
+// client cancelled.
+func (l *Limiter) Abandon() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.admitQueued()
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{Limit: l.currentLimit(), InFlight: l.inFlight, Queued: l.queue.Len()}
+}
+
+func (l *Limiter) currentLimit() int {
+	return int(l.limit)
+}
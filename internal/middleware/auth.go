@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"gin-prometheus-grafana/internal/auth"
+	"gin-prometheus-grafana/internal/reqctx"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// PrincipalKey is the gin context key holding the authenticated *auth.Principal.
+const PrincipalKey = "principal"
+
+var authAttemptsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "auth_attempts_total",
+		Help: "Total number of authentication attempts by method and result",
+	},
+	[]string{"method", "result"},
+)
+
+// Authenticate tries each authenticator in order and rejects the request with
+// 401 unless one of them accepts its credentials. The principal is stored
+// under PrincipalKey and becomes the actor recorded in the audit log.
+func Authenticate(authenticators ...auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, a := range authenticators {
+			principal, err := a.Authenticate(c.Request)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				authAttemptsTotal.WithLabelValues(a.Method(), "failure").Inc()
+				log.Printf("Authentication failed using %s: %v", a.Method(), err)
+				c.Header("WWW-Authenticate", `Bearer realm="bookstore"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+				return
+			}
+
+			authAttemptsTotal.WithLabelValues(a.Method(), "success").Inc()
+			c.Set(PrincipalKey, principal)
+			c.Set("actor", principal.Subject)
+			c.Request = c.Request.WithContext(reqctx.WithActor(c.Request.Context(), principal.Subject))
+			c.Next()
+			return
+		}
+
+		authAttemptsTotal.WithLabelValues("none", "missing").Inc()
+		c.Header("WWW-Authenticate", `Bearer realm="bookstore"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	}
+}
+
+// PrincipalFromContext returns the principal stored by Authenticate, if any.
+func PrincipalFromContext(c *gin.Context) (*auth.Principal, bool) {
+	v, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*auth.Principal)
+	return p, ok
+}
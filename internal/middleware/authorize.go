@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"gin-prometheus-grafana/internal/auth"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var authzDeniedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "authz_denied_total",
+		Help: "Total number of requests denied by the authorization policy",
+	},
+	[]string{"route", "role"},
+)
+
+// Authorize enforces policy on every request it sees. Routes without a rule
+// are denied so that new endpoints are closed until the policy covers them.
+func Authorize(policy *auth.Policy, source auth.RoleSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		principal, _ := PrincipalFromContext(c)
+		roles := source.Roles(c.Request, principal)
+
+		var scopes []string
+		if principal != nil {
+			scopes = principal.Scopes
+		}
+
+		rule, ok := policy.Match(c.Request, route)
+		if ok && rule.Allows(roles, scopes) {
+			c.Next()
+			return
+		}
+
+		roleLabel := roleLabel(policy, roles)
+		authzDeniedTotal.WithLabelValues(route, roleLabel).Inc()
+
+		if !ok {
+			log.Printf("No authorization rule for %s %s", c.Request.Method, route)
+		} else {
+			log.Printf("Denied %s %s for roles [%s]", c.Request.Method, route, roleLabel)
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	}
+}
+
+// roleLabel joins the caller's roles for the metric label. Roles the policy
+// does not know are collapsed to "other" to keep label cardinality bounded.
+func roleLabel(policy *auth.Policy, roles []string) string {
+	seen := map[string]bool{}
+	var labels []string
+	for _, role := range roles {
+		if !policy.KnownRole(role) {
+			role = "other"
+		}
+		if !seen[role] {
+			seen[role] = true
+			labels = append(labels, role)
+		}
+	}
+	if len(labels) == 0 {
+		return "none"
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// UncompressedSizeKey is the gin context key under which Compress stores the
+// response body size before compression, for PrometheusMiddleware.
+const UncompressedSizeKey = "uncompressed_response_size"
+
+// CompressConfig controls which responses are compressed.
+type CompressConfig struct {
+	// MinSize is the smallest body, in bytes, worth compressing. Smaller
+	// responses are sent as-is.
+	MinSize int
+	// ContentTypes lists the compressible media types. Entries ending in "/"
+	// match a whole family, e.g. "text/".
+	ContentTypes []string
+}
+
+// DefaultCompressConfig compresses JSON, NDJSON, CSV and other text bodies of
+// at least 1 KiB.
+var DefaultCompressConfig = CompressConfig{
+	MinSize: 1024,
+	ContentTypes: []string{
+		"application/json",
+		"application/x-ndjson",
+		"application/problem+json",
+		"application/javascript",
+		"application/xml",
+		"image/svg+xml",
+		"text/",
+	},
+}
+
+// Encodings in server preference order.
+var compressEncodings = []string{"zstd", "gzip", "deflate"}
+
+var (
+	gzipPool = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}
+	flatePool = sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	}}
+	zstdPool = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// Compress negotiates gzip, deflate or zstd from Accept-Encoding and
+// compresses eligible responses. It must run inside PrometheusMiddleware so
+// that http_response_size_bytes sees the bytes actually sent; the size before
+// compression is published under UncompressedSizeKey.
+func Compress(cfg CompressConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		w := &compressWriter{
+			ResponseWriter: original,
+			cfg:            &cfg,
+			encoding:       negotiateEncoding(c.GetHeader("Accept-Encoding")),
+		}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = original
+			c.Set(UncompressedSizeKey, w.size)
+		}()
+
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks the preferred encoding the client accepts with a
+// non-zero quality, or "" for identity.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range compressEncodings {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the body until MinSize bytes have been written (or
+// the handler flushes), then either streams it through an encoder or writes
+// it unchanged.
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      *CompressConfig
+	encoding string
+
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+	release func()
+	size    int
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.size += len(p)
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written also counts buffered bytes so handlers do not start a second body.
+func (w *compressWriter) Written() bool {
+	return w.decided || len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush commits to compressing (the final size is unknown for a streamed
+// response) and pushes everything written so far to the client.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) decide(largeEnough bool) error {
+	w.decided = true
+
+	header := w.ResponseWriter.Header()
+	compressible := w.compressibleType(header.Get("Content-Type"))
+	if compressible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	status := w.ResponseWriter.Status()
+	if largeEnough && compressible && w.encoding != "" && header.Get("Content-Encoding") == "" &&
+		status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.enc, w.release = newEncoder(w.encoding, w.ResponseWriter)
+	}
+
+	data := w.buf
+	w.buf = nil
+	if len(data) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(data)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(data)
+	return err
+}
+
+func (w *compressWriter) compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range w.cfg.ContentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.release()
+		w.enc = nil
+	}
+}
+
+func newEncoder(encoding string, dst io.Writer) (io.WriteCloser, func()) {
+	switch encoding {
+	case "gzip":
+		gz := gzipPool.Get().(*gzip.Writer)
+		gz.Reset(dst)
+		return gz, func() { gzipPool.Put(gz) }
+	case "deflate":
+		fl := flatePool.Get().(*flate.Writer)
+		fl.Reset(dst)
+		return fl, func() { flatePool.Put(fl) }
+	default:
+		zw := zstdPool.Get().(*zstd.Encoder)
+		zw.Reset(dst)
+		return zw, func() { zstdPool.Put(zw) }
+	}
+}
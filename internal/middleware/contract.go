@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"gin-prometheus-grafana/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var contractViolationsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "openapi_contract_violations_total",
+		Help: "Total number of requests and responses that violated the OpenAPI contract",
+	},
+	[]string{"route", "direction"},
+)
+
+// ContractConfig controls ValidateContract.
+type ContractConfig struct {
+	// MaxBodyBytes caps the JSON request bodies read for validation.
+	MaxBodyBytes int64
+	// ValidateResponses also checks JSON responses with a documented status.
+	// Responses are buffered to do so, and a violating one is replaced by a
+	// 500, so this is meant for tests and staging.
+	ValidateResponses bool
+}
+
+// ValidateContract rejects requests whose parameters or JSON body break the
+// OpenAPI document with 400 before they reach the handler. Bodies of
+// operations documented as JSON are checked regardless of Content-Type. Routes the
+// document does not describe pass through unchecked.
+func ValidateContract(doc *openapi.Document, cfg ContractConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		op := doc.Operation(c.Request.Method, route)
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		violations := doc.ValidateParameters(op, c.Param, c.Request.URL.Query())
+		// Handlers bind with ShouldBindJSON, which ignores Content-Type, so a
+		// JSON operation's body is validated whatever the client labels it.
+		if schema := op.JSONRequestSchema(); schema != nil {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			violations = append(violations, doc.ValidateJSON(schema, body, "body")...)
+		}
+		if len(violations) > 0 {
+			contractViolationsTotal.WithLabelValues(route, "request").Inc()
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":      "Request does not match the API contract",
+				"violations": violations,
+			})
+			return
+		}
+
+		if !cfg.ValidateResponses {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		w := &contractWriter{ResponseWriter: original}
+		c.Writer = w
+		c.Next()
+		c.Writer = original
+
+		if !w.buffering {
+			return
+		}
+		body := w.buf.Bytes()
+		if schema, documented := op.JSONResponseSchema(original.Status()); documented && schema != nil {
+			if violations := doc.ValidateJSON(schema, body, "response"); len(violations) > 0 {
+				contractViolationsTotal.WithLabelValues(route, "response").Inc()
+				log.Printf("Response for %s %s violates the API contract: %v", c.Request.Method, route, violations)
+				original.WriteHeader(http.StatusInternalServerError)
+				body, _ = json.Marshal(gin.H{
+					"error":      "Response does not match the API contract",
+					"violations": violations,
+				})
+			}
+		}
+		original.Write(body)
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// contractWriter holds back JSON response bodies until they are validated;
+// other content types, such as streamed exports, go straight through.
+type contractWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	buffering bool
+	decided   bool
+}
+
+func (w *contractWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = isJSON(w.Header().Get("Content-Type"))
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *contractWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *contractWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *contractWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
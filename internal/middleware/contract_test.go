@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gin-prometheus-grafana/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateContractIgnoresContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ValidateContract(openapi.BookAPI(), ContractConfig{MaxBodyBytes: 1 << 20}))
+	r.POST("/api/v1/books", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	for _, contentType := range []string{"application/json", "text/plain", ""} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/books", strings.NewReader(`{"title":"Dune","extra":true}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Content-Type %q: status = %d, want 400", contentType, w.Code)
+		}
+	}
+}
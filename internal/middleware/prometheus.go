@@ -48,10 +48,32 @@ var (
 		},
 		[]string{"method", "path", "status_code"},
 	)
+
+	httpProtocolRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "http_protocol_request_duration_seconds",
+			Help: "Duration of HTTP requests in seconds by negotiated protocol",
+		},
+		[]string{"protocol"},
+	)
+
+	httpResponseUncompressedSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "http_response_uncompressed_size_bytes",
+			Help: "Size of HTTP responses in bytes before compression",
+		},
+		[]string{"method", "path", "status_code"},
+	)
 )
 
 func PrometheusMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// CORS preflights are counted by http_cors_preflight_total instead
+		if IsPreflight(c.Request) {
+			c.Next()
+			return
+		}
+
 		start := time.Now()
 		
 		// Increment in-flight requests
@@ -72,11 +94,22 @@ func PrometheusMiddleware() gin.HandlerFunc {
 		
 		httpRequestsTotal.WithLabelValues(c.Request.Method, c.FullPath(), statusCode).Inc()
 		httpRequestDuration.WithLabelValues(c.Request.Method, c.FullPath(), statusCode).Observe(duration.Seconds())
+		httpProtocolRequestDuration.WithLabelValues(c.Request.Proto).Observe(duration.Seconds())
 		
 		// Record response size
 		responseSize := c.Writer.Size()
 		if responseSize > 0 {
 			httpResponseSize.WithLabelValues(c.Request.Method, c.FullPath(), statusCode).Observe(float64(responseSize))
 		}
+
+		// Compress reports the body size before encoding; without it the
+		// wire size is the uncompressed size
+		uncompressedSize := responseSize
+		if v, ok := c.Get(UncompressedSizeKey); ok {
+			uncompressedSize = v.(int)
+		}
+		if uncompressedSize > 0 {
+			httpResponseUncompressedSize.WithLabelValues(c.Request.Method, c.FullPath(), statusCode).Observe(float64(uncompressedSize))
+		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var httpPanicsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_panics_total",
+		Help: "Total number of panics recovered while handling HTTP requests",
+	},
+	[]string{"path"},
+)
+
+// CrashReport describes a recovered panic. It is logged as JSON and, when a
+// dump directory is configured, written there as a file of its own.
+type CrashReport struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Actor     string    `json:"actor,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Route     string    `json:"route"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+}
+
+// Recovery turns a panic in a later handler into a 500 JSON response, counts
+// it in http_panics_total and logs a CrashReport. If dumpDir is not empty each
+// report is also saved there. Panics caused by the client hanging up are only
+// logged, since there is nobody left to answer.
+func Recovery(dumpDir string) gin.HandlerFunc {
+	if dumpDir != "" {
+		if err := os.MkdirAll(dumpDir, 0o755); err != nil {
+			log.Printf("Crash dumps disabled, cannot create %s: %v", dumpDir, err)
+			dumpDir = ""
+		}
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			if isBrokenPipe(rec) {
+				log.Printf("Client disconnected during %s %s: %v", c.Request.Method, c.Request.URL.Path, rec)
+				c.Abort()
+				return
+			}
+
+			report := CrashReport{
+				Time:      time.Now().UTC(),
+				RequestID: c.GetString("request_id"),
+				Actor:     c.GetString("actor"),
+				Method:    c.Request.Method,
+				Path:      c.Request.URL.Path,
+				Route:     c.FullPath(),
+				Panic:     fmt.Sprint(rec),
+				Stack:     string(debug.Stack()),
+			}
+			httpPanicsTotal.WithLabelValues(report.Route).Inc()
+
+			data, _ := json.Marshal(report)
+			log.Printf("Panic recovered: %s", data)
+			if dumpDir != "" {
+				if err := writeCrashDump(dumpDir, report, data); err != nil {
+					log.Printf("Failed to write crash dump: %v", err)
+				}
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":      "Internal server error",
+				"request_id": report.RequestID,
+			})
+		}()
+
+		c.Next()
+	}
+}
+
+func writeCrashDump(dir string, report CrashReport, data []byte) error {
+	name := report.Time.Format("20060102T150405.000000000Z")
+	if report.RequestID != "" {
+		name += "-" + strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r == os.PathSeparator {
+				return '_'
+			}
+			return r
+		}, report.RequestID)
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), data, 0o644)
+}
+
+// isBrokenPipe reports whether a panic value is a write to a connection the
+// client has already closed.
+func isBrokenPipe(rec interface{}) bool {
+	err, ok := rec.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
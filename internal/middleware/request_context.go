@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gin-prometheus-grafana/internal/auth"
+	"gin-prometheus-grafana/internal/reqctx"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	ActorHeader     = "X-Actor"
+
+	// maxRequestIDLength bounds client-supplied IDs so they fit the audit log column.
+	maxRequestIDLength = 64
+)
+
+// RequestID propagates the caller's X-Request-ID, or generates one, and stores
+// it in both the gin context and the request context.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Request = c.Request.WithContext(reqctx.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// Actor records who is making the request for the audit log, taken from the
+// X-Actor header and defaulting to "anonymous".
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := c.GetHeader(ActorHeader)
+		if actor == "" {
+			actor = "anonymous"
+		}
+
+		c.Request = c.Request.WithContext(reqctx.WithActor(c.Request.Context(), actor))
+		c.Set("actor", reqctx.Actor(c.Request.Context()))
+
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// ClientIdentityKey is the gin context key holding the *auth.ClientIdentity of
+// a request made with a verified TLS client certificate.
+const ClientIdentityKey = "client_identity"
+
+// ClientIdentity exposes the verified client certificate, if any, to handlers
+// under ClientIdentityKey.
+func ClientIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := auth.ClientIdentityFromRequest(c.Request); id != nil {
+			c.Set(ClientIdentityKey, id)
+		}
+		c.Next()
+	}
+}
+
+// ClientIdentityFromContext returns the identity stored by ClientIdentity, or
+// nil if the request did not present a verified client certificate.
+func ClientIdentityFromContext(c *gin.Context) *auth.ClientIdentity {
+	v, ok := c.Get(ClientIdentityKey)
+	if !ok {
+		return nil
+	}
+	id, _ := v.(*auth.ClientIdentity)
+	return id
+}
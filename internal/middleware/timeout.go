@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var httpRequestTimeoutsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_request_timeouts_total",
+		Help: "Total number of requests that exceeded their route timeout",
+	},
+	[]string{"path"},
+)
+
+// Timeout bounds a route's handlers to d. When the deadline passes the request
+// context is cancelled and, if the handler has not started its response yet,
+// the client receives a 504 straight away; anything the handler writes after
+// that is discarded, including an error the handler reports because its
+// context was cancelled. A response that is already streaming is cut short by the
+// cancelled context instead. A zero or negative d disables the timeout.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	if d <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		w := &timeoutWriter{
+			ResponseWriter: original,
+			ctx:            ctx,
+			path:           c.FullPath(),
+			problem: timeoutProblem{
+				Type:     "about:blank",
+				Title:    http.StatusText(http.StatusGatewayTimeout),
+				Status:   http.StatusGatewayTimeout,
+				Detail:   "The request did not complete within " + d.String(),
+				Instance: c.Request.URL.Path,
+			},
+			header: original.Header().Clone(),
+		}
+		c.Writer = w
+
+		timer := time.AfterFunc(d, w.timeout)
+
+		completed := false
+		defer func() {
+			timer.Stop()
+			w.finish(completed)
+			c.Writer = original
+		}()
+
+		c.Next()
+		completed = true
+	}
+}
+
+// timeoutWriter serialises the handler's writes with the timer's 504. The
+// handler gets its own header map, copied to the real one when it commits,
+// so the timer never races with handler header updates. Once ctx's deadline
+// has passed the writer refuses to commit the handler's response and sends
+// the 504 itself, so a handler that notices the cancellation first cannot
+// answer with an error of its own.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx     context.Context
+	path    string
+	problem timeoutProblem
+
+	mu        sync.Mutex
+	header    http.Header
+	status    int
+	committed bool
+	timedOut  bool
+	exceeded  bool
+	done      bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.committed && !w.timedOut {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expired() {
+		w.commit()
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commit()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return
+	}
+	w.commit()
+	w.ResponseWriter.Flush()
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return http.StatusGatewayTimeout
+	}
+	if !w.committed && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.committed || w.timedOut
+}
+
+// commit copies the handler's headers and status to the real writer, which
+// sends them with the first body write. The caller holds mu.
+func (w *timeoutWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+// timeoutProblem is the RFC 9457 problem details body of a 504.
+type timeoutProblem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// timeout is called by the timer. It answers with a 504 problem response
+// unless the handler has already committed a response or returned.
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.countTimeout()
+	if w.committed || w.done {
+		return
+	}
+	w.writeTimeout()
+}
+
+// expired reports whether the response belongs to the middleware because the
+// deadline passed before the handler committed one, sending the 504 if the
+// timer has not got to it yet. The caller holds mu.
+func (w *timeoutWriter) expired() bool {
+	if w.timedOut {
+		return true
+	}
+	if w.committed || !errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	w.countTimeout()
+	w.writeTimeout()
+	return true
+}
+
+// countTimeout counts the request as timed out, once. The caller holds mu.
+func (w *timeoutWriter) countTimeout() {
+	if !w.exceeded {
+		w.exceeded = true
+		httpRequestTimeoutsTotal.WithLabelValues(w.path).Inc()
+	}
+}
+
+// writeTimeout sends the 504 problem response. The caller holds mu.
+func (w *timeoutWriter) writeTimeout() {
+	w.timedOut = true
+
+	body, _ := json.Marshal(w.problem)
+	w.ResponseWriter.Header().Set("Content-Type", "application/problem+json")
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
+
+// finish runs once the handler chain has returned. It waits for a timer
+// already in flight and, unless the handler panicked, forwards the headers and
+// status of a response that has no body yet (e.g. 204), or sends the 504 if
+// the deadline passed first.
+func (w *timeoutWriter) finish(completed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if completed && !w.expired() {
+		w.commit()
+	}
+	w.done = true
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeoutOwnsResponseAfterDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		want    int
+	}{
+		{"in time", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"id": 1})
+		}, http.StatusOK},
+		// Handlers report a cancelled query as their own error, as the book
+		// handlers do with 404
+		{"error body after deadline", func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}, http.StatusGatewayTimeout},
+		{"status only after deadline", func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.Status(http.StatusInternalServerError)
+		}, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/api/v1/books/:id", Timeout(20*time.Millisecond), tt.handler)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/books/1", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+// The handler can see its context expire before the timer has sent the 504;
+// its write must still lose.
+func TestTimeoutWriterRefusesWritesAfterDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	w := &timeoutWriter{
+		ResponseWriter: c.Writer,
+		ctx:            ctx,
+		problem:        timeoutProblem{Status: http.StatusGatewayTimeout},
+		header:         http.Header{},
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	if _, err := w.Write([]byte(`{"error":"Book not found"}`)); !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("write error = %v, want %v", err, http.ErrHandlerTimeout)
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	if w.Status() != http.StatusGatewayTimeout {
+		t.Errorf("logged status = %d, want %d", w.Status(), http.StatusGatewayTimeout)
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	BookID    int             `json:"book_id"`
+	Operation string          `json:"operation"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Changes   []FieldChange   `json:"changes"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// FieldChange describes a single field that differs between two versions of a book.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type BookHistory struct {
+	BookID  int          `json:"book_id"`
+	Entries []AuditEntry `json:"entries"`
+}
@@ -5,28 +5,50 @@ import (
 )
 
 type Book struct {
-	ID          int       `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title"`
-	Author      string    `json:"author" db:"author"`
-	ISBN        string    `json:"isbn" db:"isbn"`
-	Price       float64   `json:"price" db:"price"`
-	PublishedAt time.Time `json:"published_at" db:"published_at"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int        `json:"id" db:"id"`
+	Title       string     `json:"title" db:"title"`
+	Author      string     `json:"author" db:"author"`
+	ISBN        string     `json:"isbn" db:"isbn"`
+	Price       Money      `json:"price" db:"price"`
+	PublishedAt time.Time  `json:"published_at" db:"published_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type CreateBookRequest struct {
 	Title       string    `json:"title" binding:"required"`
 	Author      string    `json:"author" binding:"required"`
-	ISBN        string    `json:"isbn" binding:"required"`
-	Price       float64   `json:"price" binding:"required,min=0"`
+	ISBN        string    `json:"isbn" binding:"required,isbn"`
+	Price       Money     `json:"price" binding:"required,min=0"`
 	PublishedAt time.Time `json:"published_at" binding:"required"`
 }
 
 type UpdateBookRequest struct {
 	Title       *string    `json:"title,omitempty"`
 	Author      *string    `json:"author,omitempty"`
-	ISBN        *string    `json:"isbn,omitempty"`
-	Price       *float64   `json:"price,omitempty"`
+	ISBN        *string    `json:"isbn,omitempty" binding:"omitempty,isbn"`
+	Price       *Money     `json:"price,omitempty" binding:"omitempty,min=0"`
 	PublishedAt *time.Time `json:"published_at,omitempty"`
-}
\ No newline at end of file
+}
+type ImportRowResult struct {
+	Line  int    `json:"line"`
+	ID    int    `json:"id,omitempty"`
+	ISBN  string `json:"isbn,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Created  []ImportRowResult `json:"created"`
+	Updated  []ImportRowResult `json:"updated"`
+	Rejected []ImportRowResult `json:"rejected"`
+}
+
+// BookFilter narrows a book search. Empty fields match every book; Title and
+// Author match case-insensitive substrings.
+type BookFilter struct {
+	Title          string
+	Author         string
+	ISBN           string
+	IncludeDeleted bool
+}
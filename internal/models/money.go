@@ -0,0 +1,180 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed for prices that do not name a currency, which
+// covers rows written before the currency column existed and legacy clients
+// that send a bare number.
+const DefaultCurrency = "USD"
+
+// currencyExponents lists the supported ISO 4217 codes and their number of
+// minor-unit digits. The books.price column has two fractional digits, so
+// currencies with a larger exponent are not supported.
+var currencyExponents = map[string]int{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "INR": 2, "JPY": 0, "KRW": 0, "MXN": 2,
+	"NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2, "SGD": 2, "USD": 2, "ZAR": 2,
+}
+
+// decimalPattern matches a plain decimal amount, without exponent or sign
+// other than a leading minus.
+var decimalPattern = regexp.MustCompile(`^(-?)(\d+)(?:\.(\d+))?$`)
+
+// The books.price column is a DECIMAL(10,2): two fractional digits and an
+// absolute value below 10^8.
+const (
+	maxFractionDigits = 2
+	maxIntegerDigits  = 8
+)
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrInvalidAmount   = errors.New("invalid amount")
+)
+
+// Money is a fixed-point amount expressed in the minor units of its currency,
+// e.g. {4999, "USD"} is 49.99 USD and {1500, "JPY"} is 1500 JPY.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// ParseMoney parses a decimal amount such as "49.99" in the given currency.
+// Only plain decimals are accepted, without exponents or fractions. Amounts
+// with more fractional digits than the currency allows (other than trailing
+// zeros, as the database returns them) or of 10^8 or more are rejected.
+func ParseMoney(amount, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+
+	amount = strings.TrimSpace(amount)
+	match := decimalPattern.FindStringSubmatch(amount)
+	if match == nil {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, amount)
+	}
+	sign, whole, frac := match[1], strings.TrimLeft(match[2], "0"), match[3]
+	if len(frac) > maxFractionDigits {
+		return Money{}, fmt.Errorf("%w %q: at most %d decimal places", ErrInvalidAmount, amount, maxFractionDigits)
+	}
+	if len(frac) > exp {
+		if strings.Trim(frac[exp:], "0") != "" {
+			return Money{}, fmt.Errorf("%w %q: %s allows %d decimal places", ErrInvalidAmount, amount, currency, exp)
+		}
+		frac = frac[:exp]
+	}
+	if len(whole) > maxIntegerDigits {
+		return Money{}, fmt.Errorf("%w %q: out of range", ErrInvalidAmount, amount)
+	}
+
+	minor, err := strconv.ParseInt(sign+"0"+whole+frac+strings.Repeat("0", exp-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, amount)
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// Decimal formats the amount with the currency's number of fractional digits.
+func (m Money) Decimal() string {
+	exp := currencyExponents[m.Currency]
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if exp == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+
+	scale := int64(1)
+	for i := 0; i < exp; i++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, exp, amount%scale)
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount": "49.99", "currency": "EUR"}, whose amount
+// must be a string, as well as a bare number or string, which is read in
+// DefaultCurrency for backward compatibility. Numbers are parsed from their
+// textual form, never through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	currency := DefaultCurrency
+	raw := data
+	if len(data) > 0 && data[0] == '{' {
+		var obj moneyJSON
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if obj.Currency != "" {
+			currency = obj.Currency
+		}
+		raw = bytes.TrimSpace(obj.Amount)
+		if len(raw) > 0 && raw[0] != '"' {
+			return fmt.Errorf("%w %s: amount must be a string", ErrInvalidAmount, raw)
+		}
+	}
+
+	amount, err := rawAmount(raw)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func rawAmount(raw []byte) (string, error) {
+	if len(raw) == 0 {
+		return "", fmt.Errorf("%w: missing amount", ErrInvalidAmount)
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", fmt.Errorf("%w %s", ErrInvalidAmount, raw)
+	}
+	return n.String(), nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             Money
+		wantErr          error
+	}{
+		{"49.99", "usd", Money{4999, "USD"}, nil},
+		{"0.5", "", Money{50, "USD"}, nil},
+		{"-3.1", "EUR", Money{-310, "EUR"}, nil},
+		{"1500", "JPY", Money{1500, "JPY"}, nil},
+		// The database returns JPY prices with two zero decimals
+		{"1500.00", "JPY", Money{1500, "JPY"}, nil},
+		{"99999999.99", "USD", Money{9999999999, "USD"}, nil},
+		{"1500.5", "JPY", Money{}, ErrInvalidAmount},
+		{"49.999", "USD", Money{}, ErrInvalidAmount},
+		{"100000000", "USD", Money{}, ErrInvalidAmount},
+		{"1e3", "USD", Money{}, ErrInvalidAmount},
+		{"1/3", "USD", Money{}, ErrInvalidAmount},
+		{"+5", "USD", Money{}, ErrInvalidAmount},
+		{".5", "USD", Money{}, ErrInvalidAmount},
+		{"5", "XXX", Money{}, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.amount, tt.currency)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v; want %v, %v", tt.amount, tt.currency, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{`{"amount": "1.50", "currency": "EUR"}`, Money{150, "EUR"}, false},
+		{`{"amount": 1.5, "currency": "EUR"}`, Money{}, true},
+		{`12.5`, Money{1250, "USD"}, false},
+		{`"12.5"`, Money{1250, "USD"}, false},
+		{`1e2`, Money{}, true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, %v; want %v, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types, matching the outbox topics of book changes.
+const (
+	WebhookEventBookCreated = "book.created"
+	WebhookEventBookUpdated = "book.updated"
+	WebhookEventBookDeleted = "book.deleted"
+	WebhookEventBookPurged  = "book.purged"
+)
+
+// Webhook delivery states. A delivery is retried while pending and moves to
+// dead once its attempts are exhausted.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// Webhook is a partner subscription. The secret is only returned when the
+// webhook is created.
+type Webhook struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,http_url"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,oneof=book.created book.updated book.deleted book.purged"`
+	// Secret signs deliveries; one is generated when omitted.
+	Secret string `json:"secret,omitempty" binding:"omitempty,min=16,max=128"`
+}
+
+type UpdateWebhookRequest struct {
+	URL        *string  `json:"url,omitempty" binding:"omitempty,http_url"`
+	EventTypes []string `json:"event_types,omitempty" binding:"omitempty,min=1,dive,oneof=book.created book.updated book.deleted book.purged"`
+	Secret     *string  `json:"secret,omitempty" binding:"omitempty,min=16,max=128"`
+	Active     *bool    `json:"active,omitempty"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	MessageID      int64           `json:"message_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,376 @@
+package openapi
+
+import (
+	"gin-prometheus-grafana/internal/models"
+)
+
+// BookAPI returns the contract of the bookstore API.
+func BookAPI() *Document {
+	s := schemas{}
+	s["Error"] = &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"error": {Type: "string"}},
+		Required:   []string{"error"},
+	}
+
+	book := s.of(models.Book{})
+	jsonContent := func(schema *Schema) map[string]*MediaType {
+		return map[string]*MediaType{"application/json": {Schema: schema}}
+	}
+	ok := func(description string, schema *Schema) *Response {
+		return &Response{Description: description, Content: jsonContent(schema)}
+	}
+	fail := func(description string) *Response {
+		return &Response{Description: description, Content: jsonContent(ref("Error"))}
+	}
+	jsonBody := func(schema *Schema) *RequestBody {
+		return &RequestBody{Required: true, Content: jsonContent(schema)}
+	}
+
+	zero, one := 0.0, 1.0
+	idParam := &Parameter{Name: "id", In: "path", Required: true, Description: "Book ID", Schema: &Schema{Type: "integer", Format: "int32"}}
+	books := []string{"books"}
+
+	webhook := s.of(models.Webhook{})
+	delivery := s.of(models.WebhookDelivery{})
+	// The generator reads "min" and "oneof" as applying to the field itself;
+	// for event types they constrain the array and its items
+	eventTypes := func() *Schema {
+		one := 1
+		return &Schema{
+			Type:     "array",
+			Items:    &Schema{Type: "string", Enum: []string{models.WebhookEventBookCreated, models.WebhookEventBookUpdated, models.WebhookEventBookDeleted, models.WebhookEventBookPurged}},
+			MinItems: &one,
+		}
+	}
+	createWebhook := s.of(models.CreateWebhookRequest{})
+	s["CreateWebhookRequest"].Properties["event_types"] = eventTypes()
+	updateWebhook := s.of(models.UpdateWebhookRequest{})
+	s["UpdateWebhookRequest"].Properties["event_types"] = eventTypes()
+	webhookIDParam := &Parameter{Name: "id", In: "path", Required: true, Description: "Webhook ID", Schema: &Schema{Type: "integer", Format: "int64"}}
+	webhooks := []string{"webhooks"}
+
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:       "Bookstore API",
+			Version:     "1.0.0",
+			Description: "CRUD, import/export and audit history for the book catalog.",
+		},
+		Tags: []Tag{
+			{Name: "books", Description: "Book catalog"},
+			{Name: "webhooks", Description: "Outgoing webhooks for book changes"},
+			{Name: "system", Description: "Health and monitoring"},
+		},
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				"apiKey":     {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"clientCert": {Type: "mutualTLS"},
+			},
+		},
+		// Authentication is optional per deployment (AUTH_ENABLED)
+		Security: []map[string][]string{{"apiKey": {}}, {"bearerAuth": {}}, {"clientCert": {}}, {}},
+		Paths: map[string]*PathItem{
+			"/health": {
+				"get": {
+					OperationID: "health",
+					Summary:     "Health check",
+					Tags:        []string{"system"},
+					Responses: map[string]*Response{
+						"200": ok("Service is healthy", &Schema{Type: "object", Properties: map[string]*Schema{"status": {Type: "string", Example: "healthy"}}}),
+					},
+				},
+			},
+			"/api/v1/books": {
+				"post": {
+					OperationID: "createBook",
+					Summary:     "Create a book",
+					Tags:        books,
+					RequestBody: jsonBody(s.of(models.CreateBookRequest{})),
+					Responses: map[string]*Response{
+						"201": ok("Book created", book),
+						"400": fail("Invalid request body"),
+						"409": fail("A book with this ISBN already exists"),
+					},
+				},
+				"get": {
+					OperationID: "listBooks",
+					Summary:     "List books",
+					Description: "Supports conditional requests with If-None-Match and If-Modified-Since.",
+					Tags:        books,
+					Parameters: []*Parameter{
+						{Name: "include_deleted", In: "query", Description: "Include soft-deleted books (admin only when authorization is enabled)", Schema: &Schema{Type: "boolean"}},
+						{Name: "If-None-Match", In: "header", Schema: &Schema{Type: "string"}},
+						{Name: "If-Modified-Since", In: "header", Schema: &Schema{Type: "string"}},
+					},
+					Responses: map[string]*Response{
+						"200": {
+							Description: "All books",
+							Headers: map[string]*Header{
+								"ETag":          {Schema: &Schema{Type: "string"}},
+								"Last-Modified": {Schema: &Schema{Type: "string"}},
+							},
+							Content: jsonContent(&Schema{Type: "array", Items: book}),
+						},
+						"304": {Description: "The list has not changed"},
+					},
+				},
+			},
+			"/api/v1/books/export": {
+				"get": {
+					OperationID: "exportBooks",
+					Summary:     "Export the catalog as CSV or NDJSON",
+					Tags:        books,
+					Parameters: []*Parameter{
+						{Name: "format", In: "query", Schema: &Schema{Type: "string", Enum: []string{"csv", "ndjson"}}},
+					},
+					Responses: map[string]*Response{
+						"200": {
+							Description: "Streamed export",
+							Content: map[string]*MediaType{
+								"text/csv":             {Schema: &Schema{Type: "string"}},
+								"application/x-ndjson": {Schema: book},
+							},
+						},
+						"400": fail("Unsupported format"),
+					},
+				},
+			},
+			"/api/v1/books/events": {
+				"get": {
+					OperationID: "streamBookEvents",
+					Summary:     "Stream book changes as Server-Sent Events",
+					Description: "Each event is named created, updated or deleted and carries the event ID, the book ID, the book after the change (omitted for deletions), the actor and the time as JSON. " +
+						"A reset event means events after Last-Event-ID were lost and the catalog should be reloaded.",
+					Tags: books,
+					Parameters: []*Parameter{
+						{Name: "Last-Event-ID", In: "header", Description: "Resume after this event ID", Schema: &Schema{Type: "integer", Format: "int64", Minimum: &zero}},
+						{Name: "last_event_id", In: "query", Description: "Resume after this event ID, for clients that cannot set headers", Schema: &Schema{Type: "integer", Format: "int64", Minimum: &zero}},
+					},
+					Responses: map[string]*Response{
+						"200": {
+							Description: "Event stream",
+							Content:     map[string]*MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}},
+						},
+						"400": fail("Invalid Last-Event-ID"),
+					},
+				},
+			},
+			"/api/v1/books/ws": {
+				"get": {
+					OperationID: "subscribeBookEvents",
+					Summary:     "Subscribe to book changes over a WebSocket",
+					Description: "Send {\"type\": \"subscribe\", \"book_ids\": [...], \"authors\": [...]} or {\"type\": \"unsubscribe\", ...}; " +
+						"the server answers with {\"type\": \"subscribed\", \"subscriptions\": {...}} and then sends {\"type\": \"event\", \"event\": {...}} for every matching change.",
+					Tags: books,
+					Responses: map[string]*Response{
+						"101": {Description: "Switched to the WebSocket protocol"},
+						"400": {Description: "Not a WebSocket handshake"},
+						"403": {Description: "Origin not allowed"},
+					},
+				},
+			},
+			"/api/v1/books/import": {
+				"post": {
+					OperationID: "importBooks",
+					Summary:     "Import books from CSV or NDJSON, upserting by ISBN",
+					Tags:        books,
+					Parameters: []*Parameter{
+						{Name: "format", In: "query", Schema: &Schema{Type: "string", Enum: []string{"csv", "ndjson"}}},
+					},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content: map[string]*MediaType{
+							"multipart/form-data": {Schema: &Schema{
+								Type:       "object",
+								Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}},
+								Required:   []string{"file"},
+							}},
+							"text/csv":             {Schema: &Schema{Type: "string"}},
+							"application/x-ndjson": {Schema: s.of(models.CreateBookRequest{})},
+						},
+					},
+					Responses: map[string]*Response{
+						"200": ok("Import report", s.of(models.ImportReport{})),
+						"400": fail("Unreadable upload"),
+					},
+				},
+			},
+			"/api/v1/books/isbn/{isbn}": {
+				"get": {
+					OperationID: "getBookByISBN",
+					Summary:     "Get a book by ISBN",
+					Tags:        books,
+					Parameters: []*Parameter{
+						{Name: "isbn", In: "path", Required: true, Description: "ISBN-10 or ISBN-13", Schema: &Schema{Type: "string"}},
+					},
+					Responses: map[string]*Response{
+						"200": ok("The book", book),
+						"400": fail("Invalid ISBN"),
+						"404": fail("Book not found"),
+					},
+				},
+			},
+			"/api/v1/books/{id}": {
+				"get": {
+					OperationID: "getBook",
+					Summary:     "Get a book",
+					Tags:        books,
+					Parameters:  []*Parameter{idParam},
+					Responses: map[string]*Response{
+						"200": ok("The book", book),
+						"400": fail("Invalid book ID"),
+						"404": fail("Book not found"),
+					},
+				},
+				"put": {
+					OperationID: "updateBook",
+					Summary:     "Update a book",
+					Description: "Only the fields present in the body are changed.",
+					Tags:        books,
+					Parameters:  []*Parameter{idParam},
+					RequestBody: jsonBody(s.of(models.UpdateBookRequest{})),
+					Responses: map[string]*Response{
+						"200": ok("The updated book", book),
+						"400": fail("Invalid book ID or body"),
+						"404": fail("Book not found"),
+						"409": fail("Another book has this ISBN"),
+					},
+				},
+				"delete": {
+					OperationID: "deleteBook",
+					Summary:     "Soft-delete a book",
+					Tags:        books,
+					Parameters:  []*Parameter{idParam},
+					Responses: map[string]*Response{
+						"204": {Description: "Book deleted"},
+						"400": fail("Invalid book ID"),
+						"404": fail("Book not found"),
+					},
+				},
+			},
+			"/api/v1/books/{id}/restore": {
+				"post": {
+					OperationID: "restoreBook",
+					Summary:     "Restore a soft-deleted book",
+					Tags:        books,
+					Parameters:  []*Parameter{idParam},
+					Responses: map[string]*Response{
+						"200": ok("The restored book", book),
+						"400": fail("Invalid book ID"),
+						"404": fail("Deleted book not found"),
+					},
+				},
+			},
+			"/api/v1/books/{id}/history": {
+				"get": {
+					OperationID: "getBookHistory",
+					Summary:     "Get a book's audit history",
+					Tags:        books,
+					Parameters:  []*Parameter{idParam},
+					Responses: map[string]*Response{
+						"200": ok("Audit entries, oldest first", s.of(models.BookHistory{})),
+						"400": fail("Invalid book ID"),
+						"404": fail("Book history not found"),
+					},
+				},
+			},
+			"/api/v1/webhooks": {
+				"post": {
+					OperationID: "createWebhook",
+					Summary:     "Subscribe a URL to book changes",
+					Description: "Deliveries are POSTed as {\"id\", \"type\", \"created_at\", \"data\"} and signed in the X-Webhook-Signature header " +
+						"as t=<unix time>,v1=<hex HMAC-SHA256 of \"<t>.<body>\" keyed by the secret>. The secret is generated when omitted and only returned here.",
+					Tags:        webhooks,
+					RequestBody: jsonBody(createWebhook),
+					Responses: map[string]*Response{
+						"201": ok("The webhook, including its secret", webhook),
+						"400": fail("Invalid request body, or a URL that resolves to a loopback, private or link-local address"),
+					},
+				},
+				"get": {
+					OperationID: "listWebhooks",
+					Summary:     "List webhooks",
+					Tags:        webhooks,
+					Responses: map[string]*Response{
+						"200": ok("All webhooks", &Schema{Type: "array", Items: webhook}),
+					},
+				},
+			},
+			"/api/v1/webhooks/{id}": {
+				"get": {
+					OperationID: "getWebhook",
+					Summary:     "Get a webhook",
+					Tags:        webhooks,
+					Parameters:  []*Parameter{webhookIDParam},
+					Responses: map[string]*Response{
+						"200": ok("The webhook", webhook),
+						"400": fail("Invalid webhook ID"),
+						"404": fail("Webhook not found"),
+					},
+				},
+				"put": {
+					OperationID: "updateWebhook",
+					Summary:     "Update a webhook",
+					Description: "Only the fields present in the body are changed. Set active to false to pause the webhook: new events are not queued for it, and deliveries already queued wait until it is reactivated.",
+					Tags:        webhooks,
+					Parameters:  []*Parameter{webhookIDParam},
+					RequestBody: jsonBody(updateWebhook),
+					Responses: map[string]*Response{
+						"200": ok("The updated webhook", webhook),
+						"400": fail("Invalid webhook ID or body, or a URL that resolves to a loopback, private or link-local address"),
+						"404": fail("Webhook not found"),
+					},
+				},
+				"delete": {
+					OperationID: "deleteWebhook",
+					Summary:     "Delete a webhook and its delivery log",
+					Tags:        webhooks,
+					Parameters:  []*Parameter{webhookIDParam},
+					Responses: map[string]*Response{
+						"204": {Description: "Webhook deleted"},
+						"400": fail("Invalid webhook ID"),
+						"404": fail("Webhook not found"),
+					},
+				},
+			},
+			"/api/v1/webhooks/{id}/deliveries": {
+				"get": {
+					OperationID: "listWebhookDeliveries",
+					Summary:     "Get a webhook's delivery log, newest first",
+					Tags:        webhooks,
+					Parameters: []*Parameter{
+						webhookIDParam,
+						{Name: "status", In: "query", Schema: &Schema{Type: "string", Enum: []string{models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead}}},
+						{Name: "limit", In: "query", Description: "At most 500", Schema: &Schema{Type: "integer", Format: "int32", Minimum: &one}},
+					},
+					Responses: map[string]*Response{
+						"200": ok("Deliveries", &Schema{Type: "array", Items: delivery}),
+						"400": fail("Invalid webhook ID, status or limit"),
+						"404": fail("Webhook not found"),
+					},
+				},
+			},
+			"/api/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver": {
+				"post": {
+					OperationID: "redeliverWebhookDelivery",
+					Summary:     "Send a delivery again",
+					Description: "Resets the delivery to pending with a fresh set of attempts, e.g. to replay a dead delivery once the receiver is fixed.",
+					Tags:        webhooks,
+					Parameters: []*Parameter{
+						webhookIDParam,
+						{Name: "delivery_id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+					},
+					Responses: map[string]*Response{
+						"202": ok("The queued delivery", delivery),
+						"400": fail("Invalid webhook or delivery ID"),
+						"404": fail("Delivery not found"),
+					},
+				},
+			},
+		},
+	}
+	doc.Components.Schemas = s
+	return doc
+}
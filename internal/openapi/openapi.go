@@ -0,0 +1,304 @@
+// Package openapi describes the HTTP API as an OpenAPI 3.1 document. Schemas
+// are generated from the model structs, so they follow the json and binding
+// tags the handlers actually use.
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-prometheus-grafana/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Document is the root of an OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path, keyed by lower-case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema used by this API.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	moneyType   = reflect.TypeOf(models.Money{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas collects component schemas generated from Go types.
+type schemas map[string]*Schema
+
+// of returns the schema for the type of v, registering named structs as
+// components and referring to them by $ref.
+func (s schemas) of(v interface{}) *Schema {
+	return s.forType(reflect.TypeOf(v))
+}
+
+func (s schemas) forType(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		return nullable(s.forType(t.Elem()))
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawJSONType:
+		return &Schema{Description: "Arbitrary JSON value"}
+	case moneyType:
+		if _, ok := s["Money"]; !ok {
+			s["Money"] = moneySchema()
+		}
+		return ref("Money")
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.forType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.forType(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := s[name]; !ok {
+			// Register first so self-referencing types terminate
+			s[name] = &Schema{}
+			*s[name] = *s.structSchema(t)
+		}
+		return ref(name)
+	}
+	return &Schema{}
+}
+
+// structSchema describes a struct from its json and binding tags. Request
+// structs declare required fields with binding:"required"; in structs without
+// binding tags (responses) every field not marked omitempty is required.
+func (s schemas) structSchema(t reflect.Type) *Schema {
+	validated := false
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("binding"); ok {
+			validated = true
+		}
+	}
+
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !validated && !strings.Contains(f.Tag.Get("json"), ",omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+
+		prop := s.forType(f.Type)
+		for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+			key, value, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				schema.Required = append(schema.Required, name)
+			case "isbn":
+				prop.Description = "ISBN-10 or ISBN-13; hyphens and spaces are ignored and the value is stored as ISBN-13"
+				prop.Example = "978-0-13-468599-1"
+			case "min":
+				if prop.Ref != "" {
+					continue
+				}
+				n, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					continue
+				}
+				if prop.Type == "string" {
+					length := int(n)
+					prop.MinLength = &length
+				} else {
+					prop.Minimum = &n
+				}
+			}
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// nullable lets schema also accept null, as a Go pointer field does.
+func nullable(schema *Schema) *Schema {
+	if t, ok := schema.Type.(string); ok && schema.Ref == "" {
+		schema.Type = []string{t, "null"}
+		return schema
+	}
+	return &Schema{OneOf: []*Schema{schema, {Type: "null"}}}
+}
+
+func moneySchema() *Schema {
+	minimum, maximum := 0.0, 99999999.99
+	return &Schema{
+		Description: "A decimal amount in a currency. Responses always use the object form; requests may also send a bare number or string, which is taken as " + models.DefaultCurrency + ". Amounts must not be negative.",
+		OneOf: []*Schema{
+			{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"amount":   {Type: "string", Pattern: `^\d{1,8}(\.\d{1,2})?$`, Example: "49.99"},
+					"currency": {Type: "string", Pattern: `^[A-Z]{3}$`, Description: "ISO 4217 currency code", Example: models.DefaultCurrency},
+				},
+				Required:             []string{"amount", "currency"},
+				AdditionalProperties: false,
+			},
+			{Type: "number", Minimum: &minimum, Maximum: &maximum},
+			{Type: "string", Pattern: `^\d{1,8}(\.\d{1,2})?$`},
+		},
+	}
+}
+
+// MissingRoutes lists the registered routes that have no operation in doc.
+// Paths in ignore (e.g. /metrics) are skipped.
+func MissingRoutes(doc *Document, routes gin.RoutesInfo, ignore ...string) []string {
+	skip := make(map[string]bool, len(ignore))
+	for _, p := range ignore {
+		skip[p] = true
+	}
+
+	var missing []string
+	for _, route := range routes {
+		if skip[route.Path] {
+			continue
+		}
+		item, ok := doc.Paths[templatePath(route.Path)]
+		if !ok || (*item)[strings.ToLower(route.Method)] == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// templatePath turns a gin path such as /books/:id into /books/{id}.
+func templatePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
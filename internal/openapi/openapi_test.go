@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// TestBookAPIIsValid checks the structural rules of OpenAPI 3.1 that the
+// generated document could plausibly break.
+func TestBookAPIIsValid(t *testing.T) {
+	doc := BookAPI()
+
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Fatalf("incomplete header: openapi=%q info=%+v", doc.OpenAPI, doc.Info)
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("document does not marshal: %v", err)
+	}
+
+	tags := map[string]bool{}
+	for _, tag := range doc.Tags {
+		tags[tag.Name] = true
+	}
+	schemes := doc.Components.SecuritySchemes
+	for _, requirement := range doc.Security {
+		for name := range requirement {
+			if schemes[name] == nil {
+				t.Errorf("security requirement names unknown scheme %q", name)
+			}
+		}
+	}
+
+	methods := map[string]bool{"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true}
+	operationIDs := map[string]string{}
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/") {
+			t.Errorf("path %q must start with /", path)
+		}
+		templated := map[string]bool{}
+		for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+			templated[m[1]] = true
+		}
+
+		for method, op := range *item {
+			where := strings.ToUpper(method) + " " + path
+			if !methods[method] {
+				t.Errorf("%s: unknown method", where)
+			}
+			if op.OperationID == "" {
+				t.Errorf("%s: missing operationId", where)
+			} else if other, dup := operationIDs[op.OperationID]; dup {
+				t.Errorf("%s: operationId %q already used by %s", where, op.OperationID, other)
+			} else {
+				operationIDs[op.OperationID] = where
+			}
+			for _, tag := range op.Tags {
+				if !tags[tag] {
+					t.Errorf("%s: tag %q is not declared", where, tag)
+				}
+			}
+			if len(op.Responses) == 0 {
+				t.Errorf("%s: no responses", where)
+			}
+			for status, resp := range op.Responses {
+				if !regexp.MustCompile(`^([1-5][0-9][0-9]|[1-5]XX|default)$`).MatchString(status) {
+					t.Errorf("%s: invalid response key %q", where, status)
+				}
+				if resp.Description == "" {
+					t.Errorf("%s %s: response needs a description", where, status)
+				}
+				for _, media := range resp.Content {
+					checkRefs(t, doc, media.Schema, where+" "+status)
+				}
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					checkRefs(t, doc, media.Schema, where+" request body")
+				}
+			}
+
+			declared := map[string]bool{}
+			for _, p := range op.Parameters {
+				switch p.In {
+				case "path":
+					if !p.Required {
+						t.Errorf("%s: path parameter %q must be required", where, p.Name)
+					}
+					if !templated[p.Name] {
+						t.Errorf("%s: path parameter %q is not in the path", where, p.Name)
+					}
+					declared[p.Name] = true
+				case "query", "header", "cookie":
+				default:
+					t.Errorf("%s: parameter %q has invalid location %q", where, p.Name, p.In)
+				}
+				if p.Schema == nil {
+					t.Errorf("%s: parameter %q has no schema", where, p.Name)
+				}
+			}
+			for name := range templated {
+				if !declared[name] {
+					t.Errorf("%s: path parameter {%s} is not declared", where, name)
+				}
+			}
+		}
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		checkRefs(t, doc, schema, "components.schemas."+name)
+	}
+}
+
+// checkRefs reports $refs under schema that do not resolve to a component.
+func checkRefs(t *testing.T, doc *Document, schema *Schema, where string) {
+	t.Helper()
+	if schema == nil {
+		return
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("%s: unresolved $ref %q", where, schema.Ref)
+		}
+	}
+	checkRefs(t, doc, schema.Items, where)
+	for _, s := range schema.OneOf {
+		checkRefs(t, doc, s, where)
+	}
+	for _, s := range schema.Properties {
+		checkRefs(t, doc, s, where)
+	}
+	if extra, ok := schema.AdditionalProperties.(*Schema); ok {
+		checkRefs(t, doc, extra, where)
+	}
+}
+
+func TestMissingRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	noop := func(c *gin.Context) {}
+	r.GET("/api/v1/books/:id", noop)
+	r.GET("/metrics", noop)
+	r.PATCH("/api/v1/books/:id", noop)
+	r.GET("/api/v1/undocumented", noop)
+
+	missing := MissingRoutes(BookAPI(), r.Routes(), "/metrics")
+	want := []string{"GET /api/v1/undocumented", "PATCH /api/v1/books/:id"}
+	if strings.Join(missing, ",") != strings.Join(want, ",") {
+		t.Errorf("MissingRoutes = %v, want %v", missing, want)
+	}
+}
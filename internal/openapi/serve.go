@@ -0,0 +1,48 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves doc as JSON. The document is encoded once up front.
+func Handler(doc *Document) gin.HandlerFunc {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("openapi: cannot encode document: " + err.Error())
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	}
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Bookstore API</title>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="%s"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// docsCSP relaxes the API's default Content-Security-Policy just enough for
+// the Redoc bundle, its fonts and its web worker.
+const docsCSP = "default-src 'none'; script-src https://cdn.redoc.ly; style-src 'unsafe-inline' https://fonts.googleapis.com; " +
+	"font-src https://fonts.gstatic.com; img-src 'self' data: https://cdn.redoc.ly; worker-src blob:; connect-src 'self'; frame-ancestors 'none'"
+
+// DocsHandler serves a Redoc page rendering the document at specURL.
+func DocsHandler(specURL string) gin.HandlerFunc {
+	page := []byte(fmt.Sprintf(docsPage, specURL))
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", docsCSP)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	}
+}
@@ -0,0 +1,314 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Violation is one way in which a value breaks the contract.
+type Violation struct {
+	// Location is where the value was found, e.g. "body.price.amount" or
+	// "query.format".
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Location + ": " + v.Message
+}
+
+// Operation returns the operation for a method and a gin route path such as
+// /api/v1/books/:id, or nil if the document does not describe it.
+func (d *Document) Operation(method, route string) *Operation {
+	item, ok := d.Paths[templatePath(route)]
+	if !ok {
+		return nil
+	}
+	return (*item)[strings.ToLower(method)]
+}
+
+// ValidateParameters checks the path and query parameters of a request.
+// pathValue and queryValues look up the raw values by name.
+func (d *Document) ValidateParameters(op *Operation, pathValue func(string) string, queryValues map[string][]string) []Violation {
+	var violations []Violation
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw = pathValue(p.Name)
+			present = raw != ""
+		case "query":
+			var values []string
+			values, present = queryValues[p.Name]
+			if present && len(values) > 0 {
+				raw = values[0]
+			}
+		default:
+			continue
+		}
+
+		location := p.In + "." + p.Name
+		if !present {
+			if p.Required {
+				violations = append(violations, Violation{location, "is required"})
+			}
+			continue
+		}
+		value, err := coerceParameter(d.resolve(p.Schema), raw)
+		if err != nil {
+			violations = append(violations, Violation{location, err.Error()})
+			continue
+		}
+		violations = append(violations, d.validate(p.Schema, value, location)...)
+	}
+	return violations
+}
+
+// ValidateJSON checks a JSON document against schema.
+func (d *Document) ValidateJSON(schema *Schema, data []byte, location string) []Violation {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return []Violation{{location, "is not valid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return []Violation{{location, "contains more than one JSON value"}}
+	}
+	return d.validate(schema, value, location)
+}
+
+// coerceParameter converts a raw parameter string to the JSON type the
+// schema expects, so it can be validated like a body value.
+func coerceParameter(schema *Schema, raw string) (interface{}, error) {
+	switch primaryType(schema) {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return json.Number(raw), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, location string) []Violation {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		var first []Violation
+		for _, option := range schema.OneOf {
+			v := d.validate(option, value, location)
+			if len(v) == 0 {
+				matches++
+			} else if first == nil || typeMatches(schemaTypes(d.resolve(option)), value) {
+				// Report against the form the value was evidently meant to take
+				first = v
+			}
+		}
+		switch {
+		case matches == 1:
+			return nil
+		case matches == 0:
+			return first
+		default:
+			return []Violation{{location, "matches more than one allowed form"}}
+		}
+	}
+
+	if types := schemaTypes(schema); len(types) > 0 && !typeMatches(types, value) {
+		return []Violation{{location, fmt.Sprintf("must be of type %s, got %s", strings.Join(types, " or "), jsonType(value))}}
+	}
+
+	var violations []Violation
+	switch v := value.(type) {
+	case string:
+		if len(schema.Enum) > 0 && !containsString(schema.Enum, v) {
+			violations = append(violations, Violation{location, "must be one of " + strings.Join(schema.Enum, ", ")})
+		}
+		if schema.MinLength != nil && len([]rune(v)) < *schema.MinLength {
+			violations = append(violations, Violation{location, fmt.Sprintf("must be at least %d characters", *schema.MinLength)})
+		}
+		if schema.Pattern != "" && !compilePattern(schema.Pattern).MatchString(v) {
+			violations = append(violations, Violation{location, "must match " + schema.Pattern})
+		}
+		if schema.Format == "date-time" && !isDateTime(v) {
+			violations = append(violations, Violation{location, "must be an RFC 3339 date-time"})
+		}
+	case json.Number:
+		if schema.Minimum != nil {
+			if f, _ := v.Float64(); f < *schema.Minimum {
+				violations = append(violations, Violation{location, fmt.Sprintf("must be at least %v", *schema.Minimum)})
+			}
+		}
+		if schema.Maximum != nil {
+			if f, _ := v.Float64(); f > *schema.Maximum {
+				violations = append(violations, Violation{location, fmt.Sprintf("must be at most %v", *schema.Maximum)})
+			}
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			violations = append(violations, Violation{location, fmt.Sprintf("must have at least %d items", *schema.MinItems)})
+		}
+		for i, item := range v {
+			violations = append(violations, d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				violations = append(violations, Violation{location + "." + name, "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, known := schema.Properties[name]
+			switch {
+			case known:
+				violations = append(violations, d.validate(prop, v[name], location+"."+name)...)
+			case schema.AdditionalProperties == false:
+				violations = append(violations, Violation{location + "." + name, "is not a known field"})
+			default:
+				if extra, ok := schema.AdditionalProperties.(*Schema); ok {
+					violations = append(violations, d.validate(extra, v[name], location+"."+name)...)
+				}
+			}
+		}
+	}
+	return violations
+}
+
+func schemaTypes(schema *Schema) []string {
+	switch t := schema.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+func primaryType(schema *Schema) string {
+	if schema == nil {
+		return ""
+	}
+	for _, t := range schemaTypes(schema) {
+		if t != "null" {
+			return t
+		}
+	}
+	return ""
+}
+
+func typeMatches(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+var rfc3339 = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+
+func isDateTime(s string) bool {
+	return rfc3339.MatchString(s)
+}
+
+var patterns sync.Map
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONRequestSchema returns the schema of an application/json request body, or
+// nil if the operation takes none.
+func (op *Operation) JSONRequestSchema() *Schema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	if mt, ok := op.RequestBody.Content["application/json"]; ok {
+		return mt.Schema
+	}
+	return nil
+}
+
+// JSONResponseSchema returns the application/json schema documented for
+// status. ok is false when the status is not documented at all.
+func (op *Operation) JSONResponseSchema(status int) (schema *Schema, ok bool) {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return nil, false
+	}
+	if mt, found := resp.Content["application/json"]; found {
+		return mt.Schema, true
+	}
+	return nil, true
+}
@@ -0,0 +1,75 @@
+// Package outbox implements the transactional outbox: writers store a message
+// in the same transaction as the change it describes, and a Relay publishes
+// stored messages afterwards, so a crash can delay a message but never lose
+// it or publish one for a change that rolled back.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BrokerConsumer is the consumer that relays to an external Publisher such as
+// NATS or Kafka. Rows written before consumers were introduced belong to it.
+const BrokerConsumer = "broker"
+
+// Schema creates the outbox table. Each change is stored once per consumer,
+// and each consumer's rows are published in ID order per key by its own
+// relay; the partial indexes keep polling cheap once most rows have been sent.
+const Schema = `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		consumer VARCHAR(32) NOT NULL DEFAULT 'broker',
+		topic VARCHAR(64) NOT NULL,
+		key VARCHAR(64) NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		last_error TEXT,
+		sent_at TIMESTAMPTZ
+	);
+	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS consumer VARCHAR(32) NOT NULL DEFAULT 'broker';
+	DROP INDEX IF EXISTS outbox_pending_idx;
+	DROP INDEX IF EXISTS outbox_pending_key_idx;
+	CREATE INDEX IF NOT EXISTS outbox_consumer_pending_idx ON outbox (consumer, next_attempt_at, id) WHERE sent_at IS NULL;
+	CREATE INDEX IF NOT EXISTS outbox_consumer_key_idx ON outbox (consumer, key, id) WHERE sent_at IS NULL;
+`
+
+// Message is a stored outbox row as handed to a Publisher. Topic names the
+// kind of change (e.g. "book.updated") and Key the entity it concerns.
+// Publishers may see a message more than once, so consumers should
+// deduplicate on ID.
+type Message struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+	Attempts  int
+}
+
+// Publisher delivers messages to wherever they are consumed. A nil error
+// means the message was accepted and will not be sent again.
+type Publisher interface {
+	Publish(ctx context.Context, m Message) error
+}
+
+// Insert stores a message in tx for each of consumers. The copies become
+// visible to the consumers' relays only if tx commits.
+func Insert(ctx context.Context, tx *sql.Tx, consumers []string, topic, key string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO outbox (consumer, topic, key, payload) SELECT unnest($1::text[]), $2, $3, $4`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(consumers), topic, key, string(data)); err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return nil
+}
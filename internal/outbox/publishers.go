@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// LogPublisher writes messages to the application log, which is useful in
+// development and as a placeholder until a broker is configured.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, m Message) error {
+	log.Printf("Outbox message %d: %s key=%s %s", m.ID, m.Topic, m.Key, m.Payload)
+	return nil
+}
+
+// WebhookPublisher POSTs each message's payload as JSON to a fixed URL. The
+// topic and message ID travel in headers; any 2xx response is success.
+type WebhookPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Outbox-Topic", m.Topic)
+	req.Header.Set("X-Outbox-Message-ID", strconv.FormatInt(m.ID, 10))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// NATSPublisher publishes to JetStream on the subject SubjectPrefix + "." +
+// topic and waits for the stream's acknowledgement. The message ID is sent
+// as Nats-Msg-Id, so JetStream drops redeliveries within its duplicate
+// window. A stream must already capture the subjects.
+type NATSPublisher struct {
+	conn          *nats.Conn
+	js            nats.JetStreamContext
+	subjectPrefix string
+}
+
+func NewNATSPublisher(url, subjectPrefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("bookstore-outbox"))
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATSPublisher{conn: conn, js: js, subjectPrefix: subjectPrefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, m Message) error {
+	msg := nats.NewMsg(p.subjectPrefix + "." + m.Topic)
+	msg.Data = m.Payload
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(m.ID, 10))
+	msg.Header.Set("Content-Type", "application/json")
+	_, err := p.js.PublishMsg(msg, nats.Context(ctx))
+	return err
+}
+
+func (p *NATSPublisher) Close() error {
+	p.conn.Close()
+	return nil
+}
+
+// KafkaPublisher writes messages to one Kafka topic, keyed by the message key
+// so that each entity's changes stay in order on one partition. The outbox
+// topic and ID are sent as the "type" and "message-id" headers. Writes wait
+// for all in-sync replicas.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// The relay publishes one message at a time; don't wait to fill a batch
+		BatchSize: 1,
+	}}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, m Message) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(m.Key),
+		Value: m.Payload,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(m.Topic)},
+			{Key: "message-id", Value: []byte(strconv.FormatInt(m.ID, 10))},
+		},
+		Time: m.CreatedAt,
+	})
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,251 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	outboxPublishedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbox_messages_published_total",
+			Help: "Total number of outbox publish attempts by consumer and result",
+		},
+		[]string{"consumer", "result"},
+	)
+
+	outboxPublishDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "outbox_publish_duration_seconds",
+			Help: "Duration of outbox publish attempts in seconds",
+		},
+		[]string{"consumer"},
+	)
+
+	outboxBacklog = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outbox_backlog",
+			Help: "Number of outbox messages not yet published by consumer",
+		},
+		[]string{"consumer"},
+	)
+
+	outboxLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "outbox_lag_seconds",
+			Help: "Age of the oldest outbox message not yet published by consumer",
+		},
+		[]string{"consumer"},
+	)
+)
+
+// RelayConfig configures a Relay.
+type RelayConfig struct {
+	// PollInterval is the pause between polls when the outbox is drained.
+	PollInterval time.Duration
+	// BatchSize is the most messages claimed per poll.
+	BatchSize int
+	// Lease is how long claimed messages are reserved for this relay. Other
+	// relays may claim them again once it passes, so it should cover
+	// publishing a whole batch; a relay stops publishing its batch when the
+	// lease runs out.
+	Lease time.Duration
+	// PublishTimeout bounds each Publish call, so a broker that never
+	// acknowledges fails the message instead of stalling the relay.
+	PublishTimeout time.Duration
+	// A failed message is retried after BaseBackoff, doubling with every
+	// further failure up to MaxBackoff. Messages are never given up on.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Retention is how long published messages are kept before deletion.
+	Retention time.Duration
+}
+
+var DefaultRelayConfig = RelayConfig{
+	PollInterval:   time.Second,
+	BatchSize:      100,
+	Lease:          5 * time.Minute,
+	PublishTimeout: 30 * time.Second,
+	BaseBackoff:    time.Second,
+	MaxBackoff:     5 * time.Minute,
+	Retention:      7 * 24 * time.Hour,
+}
+
+// Relay moves one consumer's messages from the outbox table to a Publisher.
+// Consumers have their own rows and relays, so one whose publisher is down
+// does not hold up the others. Several replicas may run a relay for the same
+// consumer: rows are claimed by
+// leasing them, pushing next_attempt_at past the time the relay needs to
+// publish them, and a message is only claimed once every earlier message with
+// the same key has been published, which keeps each key in order even across
+// retries. No transaction is held while publishing.
+type Relay struct {
+	db        *sql.DB
+	consumer  string
+	publisher Publisher
+	cfg       RelayConfig
+}
+
+func NewRelay(db *sql.DB, consumer string, publisher Publisher, cfg RelayConfig) *Relay {
+	return &Relay{db: db, consumer: consumer, publisher: publisher, cfg: cfg}
+}
+
+// Run relays messages until ctx is done. A poll that found work is followed
+// straight away by the next, so a backlog (including several messages for
+// one key, which are claimed one per poll) drains without waiting.
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var lastCleanup time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		claimed, err := r.relayBatch(ctx)
+		if err != nil {
+			log.Printf("Outbox relay for %s failed: %v", r.consumer, err)
+		}
+		r.updateGauges(ctx)
+		if time.Since(lastCleanup) >= time.Hour {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		wait := r.cfg.PollInterval
+		if err == nil && claimed > 0 {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// relayBatch leases up to BatchSize due messages, publishes them in order and
+// records each outcome as soon as it is known, so a failure only delays the
+// message that failed.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	leased := time.Now()
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox SET next_attempt_at = now() + $2::double precision * interval '1 second'
+		WHERE id IN (
+		  SELECT id FROM outbox o
+		  WHERE consumer = $3 AND sent_at IS NULL AND next_attempt_at <= now()
+		    AND NOT EXISTS (
+		      SELECT 1 FROM outbox earlier
+		      WHERE earlier.consumer = o.consumer AND earlier.key = o.key AND earlier.sent_at IS NULL AND earlier.id < o.id
+		    )
+		  ORDER BY id
+		  LIMIT $1
+		  FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, key, payload, created_at, attempts
+	`, r.cfg.BatchSize, r.cfg.Lease.Seconds(), r.consumer)
+	if err != nil {
+		return 0, err
+	}
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Key, &payload, &m.CreatedAt, &m.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		m.Payload = payload
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	// RETURNING does not preserve the subquery's order
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+
+	for i, m := range messages {
+		if time.Since(leased) >= r.cfg.Lease {
+			// The rest may already belong to another relay; they are due again
+			// now that the lease is over
+			log.Printf("Outbox lease for %s expired with %d messages unpublished", r.consumer, len(messages)-i)
+			return i, nil
+		}
+
+		pubErr := r.publish(ctx, m)
+		if pubErr == nil {
+			_, err = r.db.ExecContext(ctx, `UPDATE outbox SET sent_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, m.ID)
+		} else {
+			backoff := r.backoff(m.Attempts + 1)
+			log.Printf("Failed to publish outbox message %d (%s) to %s, attempt %d, retrying in %s: %v", m.ID, m.Topic, r.consumer, m.Attempts+1, backoff, pubErr)
+			_, err = r.db.ExecContext(ctx, `
+				UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = now() + $2::double precision * interval '1 second'
+				WHERE id = $3
+			`, pubErr.Error(), backoff.Seconds(), m.ID)
+		}
+		if err != nil {
+			// The message stays leased and is published again once the
+			// lease runs out
+			return i, err
+		}
+	}
+
+	return len(messages), nil
+}
+
+// publish hands one message to the publisher, giving up after
+// PublishTimeout.
+func (r *Relay) publish(ctx context.Context, m Message) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := r.publisher.Publish(ctx, m)
+	outboxPublishDuration.WithLabelValues(r.consumer).Observe(time.Since(start).Seconds())
+	if err != nil {
+		outboxPublishedTotal.WithLabelValues(r.consumer, "failure").Inc()
+		return err
+	}
+	outboxPublishedTotal.WithLabelValues(r.consumer, "success").Inc()
+	return nil
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.BaseBackoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.MaxBackoff)
+}
+
+func (r *Relay) updateGauges(ctx context.Context) {
+	var backlog int
+	var lag sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT count(*), EXTRACT(EPOCH FROM now() - min(created_at))
+		FROM outbox WHERE consumer = $1 AND sent_at IS NULL
+	`, r.consumer).Scan(&backlog, &lag)
+	if err != nil {
+		log.Printf("Failed to measure outbox backlog: %v", err)
+		return
+	}
+	outboxBacklog.WithLabelValues(r.consumer).Set(float64(backlog))
+	outboxLag.WithLabelValues(r.consumer).Set(lag.Float64)
+}
+
+func (r *Relay) cleanup(ctx context.Context) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM outbox WHERE consumer = $1 AND sent_at < $2`, r.consumer, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		log.Printf("Failed to delete published outbox messages: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Deleted %d published %s outbox messages older than %s", n, r.consumer, r.cfg.Retention)
+	}
+}
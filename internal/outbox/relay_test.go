@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingPublisher never acknowledges, like a broker that lost the ack. It
+// only returns once its context is done.
+type blockingPublisher struct{}
+
+func (blockingPublisher) Publish(ctx context.Context, m Message) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRelayPublishTimesOut(t *testing.T) {
+	cfg := DefaultRelayConfig
+	cfg.PublishTimeout = 50 * time.Millisecond
+	r := NewRelay(nil, BrokerConsumer, blockingPublisher{}, cfg)
+
+	// The relay runs with a context that is never cancelled
+	done := make(chan error, 1)
+	go func() { done <- r.publish(context.Background(), Message{ID: 1, Topic: "book.created", Key: "1"}) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("publish error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish did not give up after PublishTimeout")
+	}
+}
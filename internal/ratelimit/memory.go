@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery controls how often, in Take calls, idle buckets are dropped.
+const sweepEvery = 1024
+
+// MemoryStore keeps buckets in process memory. Buckets that have refilled
+// completely are dropped, since they are indistinguishable from new ones.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	limits  map[string]Limit
+	calls   int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		limits:  make(map[string]Limit),
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	s.limits[key] = limit
+	res := b.take(limit, now)
+
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		s.sweep(now)
+	}
+	return res, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		limit := s.limits[key]
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(s.buckets, key)
+			delete(s.limits, key)
+		}
+	}
+}
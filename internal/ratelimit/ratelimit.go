@@ -0,0 +1,89 @@
+// Package ratelimit implements token-bucket rate limiting behind a Store
+// interface so buckets can live in process or in a shared backend.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describes a token bucket: it refills at Rate tokens per second and
+// holds at most Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses "rate:burst", e.g. "50:100" for 50 requests per second
+// with bursts of up to 100.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(s, ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must be rate:burst", s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q has invalid rate", s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 1 {
+		return Limit{}, fmt.Errorf("rate limit %q has invalid burst", s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// Fill is the bucket level as a fraction of Burst, between 0 and 1.
+	Fill float64
+	// RetryAfter is how long until a token is available; zero when allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps token buckets. Implementations must be safe for concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is the state shared by Store implementations.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket up to now and tries to consume one token.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	burst := float64(limit.Burst)
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * limit.Rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+
+	res := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Fill = b.tokens / burst
+	res.Reset = seconds((burst - b.tokens) / limit.Rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/reqctx"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	auditCreate  = "create"
+	auditUpdate  = "update"
+	auditDelete  = "delete"
+	auditRestore = "restore"
+	auditPurge   = "purge"
+)
+
+// diffIgnoredFields are bumped on every write and would otherwise show up in
+// every diff.
+var diffIgnoredFields = map[string]bool{"updated_at": true}
+
+// withTx runs fn inside a transaction, committing when fn succeeds and rolling
+// back otherwise. Errors from fn are returned unwrapped so callers can still
+// compare against sql.ErrNoRows.
+func (r *BookRepository) withTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockBook loads a live book and locks its row for the rest of the transaction,
+// giving the audit log a consistent "before" image.
+func lockBook(ctx context.Context, tx *sql.Tx, id int) (*models.Book, error) {
+	query := `
+		SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+		FROM books WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+	return scanBook(tx.QueryRowContext(ctx, query, id))
+}
+
+// writeAudit records a mutation in book_audit using the caller's transaction.
+// The actor and request ID come from the request context.
+func writeAudit(ctx context.Context, tx *sql.Tx, operation string, bookID int, before, after *models.Book) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	actor := reqctx.Actor(ctx)
+	if actor == "" {
+		actor = "system"
+	}
+
+	query := `
+		INSERT INTO book_audit (book_id, operation, actor, request_id, before_data, after_data, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.ExecContext(ctx, query, bookID, operation, actor, reqctx.RequestID(ctx), beforeJSON, afterJSON, time.Now())
+	if err != nil {
+		dbQueryTotal.WithLabelValues("insert", "book_audit", "error").Inc()
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+
+	dbQueryTotal.WithLabelValues("insert", "book_audit", "success").Inc()
+	return nil
+}
+
+// snapshot encodes a book for a JSONB column; a nil book maps to NULL.
+func snapshot(book *models.Book) (interface{}, error) {
+	if book == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// GetBookHistory returns every audit entry for a book, oldest first, each
+// annotated with the fields that changed in that version.
+func (r *BookRepository) GetBookHistory(ctx context.Context, id int) (*models.BookHistory, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("select", "book_audit").Observe(time.Since(start).Seconds())
+	}()
+
+	query := `
+		SELECT id, book_id, operation, actor, request_id, before_data, after_data, changed_at
+		FROM book_audit WHERE book_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		dbQueryTotal.WithLabelValues("select", "book_audit", "error").Inc()
+		log.Printf("Error getting history for book ID %d: %v", id, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &models.BookHistory{BookID: id, Entries: []models.AuditEntry{}}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.BookID, &entry.Operation, &entry.Actor, &entry.RequestID, &before, &after, &entry.ChangedAt); err != nil {
+			dbQueryTotal.WithLabelValues("select", "book_audit", "error").Inc()
+			log.Printf("Error scanning audit row: %v", err)
+			return nil, err
+		}
+		entry.Before = rawJSON(before)
+		entry.After = rawJSON(after)
+		if entry.Changes, err = diffSnapshots(before, after); err != nil {
+			dbQueryTotal.WithLabelValues("select", "book_audit", "error").Inc()
+			return nil, fmt.Errorf("audit entry %d: %w", entry.ID, err)
+		}
+		history.Entries = append(history.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		dbQueryTotal.WithLabelValues("select", "book_audit", "error").Inc()
+		return nil, err
+	}
+
+	if len(history.Entries) == 0 {
+		dbQueryTotal.WithLabelValues("select", "book_audit", "not_found").Inc()
+		return nil, fmt.Errorf("no history for book with id %d", id)
+	}
+
+	dbQueryTotal.WithLabelValues("select", "book_audit", "success").Inc()
+	return history, nil
+}
+
+func rawJSON(data []byte) json.RawMessage {
+	if data == nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(data)
+}
+
+// diffSnapshots compares two JSON book images field by field. A missing image
+// (create has no before, and so on) is treated as an empty object.
+func diffSnapshots(before, after []byte) ([]models.FieldChange, error) {
+	from := map[string]interface{}{}
+	to := map[string]interface{}{}
+	if before != nil {
+		if err := json.Unmarshal(before, &from); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &to); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := map[string]bool{}
+	for k := range from {
+		fields[k] = true
+	}
+	for k := range to {
+		fields[k] = true
+	}
+
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if !diffIgnoredFields[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []models.FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(from[name], to[name]) {
+			changes = append(changes, models.FieldChange{Field: name, From: from[name], To: to[name]})
+		}
+	}
+	return changes, nil
+}
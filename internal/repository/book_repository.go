@@ -1,16 +1,30 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"gin-prometheus-grafana/internal/isbn"
 	"gin-prometheus-grafana/internal/models"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// uniqueViolation is the PostgreSQL error code for a duplicate key, which on
+// books can only be the ISBN.
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
 var (
 	dbQueryDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -30,32 +44,76 @@ var (
 )
 
 type BookRepository struct {
-	db *sql.DB
+	db              *sql.DB
+	outboxConsumers []string
 }
 
 func NewBookRepository(db *sql.DB) *BookRepository {
 	return &BookRepository{db: db}
 }
 
-func (r *BookRepository) CreateBook(book *models.CreateBookRequest) (*models.Book, error) {
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook reads the book columns in the order used by every query in this
+// file, followed by any extra destinations. The DECIMAL price is read as text
+// so it converts to minor units without passing through float64.
+func scanBook(s rowScanner, extra ...interface{}) (*models.Book, error) {
+	var book models.Book
+	var price, currency string
+	var deletedAt sql.NullTime
+	dest := append([]interface{}{&book.ID, &book.Title, &book.Author, &book.ISBN, &price, &currency, &book.PublishedAt, &book.CreatedAt, &book.UpdatedAt, &deletedAt}, extra...)
+	if err := s.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		book.DeletedAt = &deletedAt.Time
+	}
+
+	money, err := models.ParseMoney(price, currency)
+	if err != nil {
+		return nil, fmt.Errorf("book %d has invalid price: %w", book.ID, err)
+	}
+	book.Price = money
+	return &book, nil
+}
+
+func (r *BookRepository) CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error) {
 	start := time.Now()
 	defer func() {
 		dbQueryDuration.WithLabelValues("create", "books").Observe(time.Since(start).Seconds())
 	}()
 
+	canonicalISBN, err := isbn.Normalize(book.ISBN)
+	if err != nil {
+		dbQueryTotal.WithLabelValues("create", "books", "error").Inc()
+		return nil, fmt.Errorf("isbn %q: %w", book.ISBN, err)
+	}
+
 	query := `
-		INSERT INTO books (title, author, isbn, price, published_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, title, author, isbn, price, published_at, created_at, updated_at
+		INSERT INTO books (title, author, isbn, price, currency, published_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
 	`
 	
 	now := time.Now()
-	row := r.db.QueryRow(query, book.Title, book.Author, book.ISBN, book.Price, book.PublishedAt, now, now)
-	
-	var result models.Book
-	err := row.Scan(&result.ID, &result.Title, &result.Author, &result.ISBN, &result.Price, &result.PublishedAt, &result.CreatedAt, &result.UpdatedAt)
+	var result *models.Book
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, query, book.Title, book.Author, canonicalISBN, book.Price.Decimal(), book.Price.Currency, book.PublishedAt, now, now)
+		var err error
+		if result, err = scanBook(row); err != nil {
+			return err
+		}
+		return r.recordChange(ctx, tx, auditCreate, result.ID, nil, result)
+	})
 	
 	if err != nil {
+		if isUniqueViolation(err) {
+			dbQueryTotal.WithLabelValues("create", "books", "conflict").Inc()
+			return nil, fmt.Errorf("isbn %s: %w", canonicalISBN, ErrDuplicateISBN)
+		}
 		dbQueryTotal.WithLabelValues("create", "books", "error").Inc()
 		log.Printf("Error creating book: %v", err)
 		return nil, err
@@ -63,28 +121,27 @@ func (r *BookRepository) CreateBook(book *models.CreateBookRequest) (*models.Boo
 	
 	dbQueryTotal.WithLabelValues("create", "books", "success").Inc()
 	log.Printf("Created book: ID=%d, Title=%s", result.ID, result.Title)
-	return &result, nil
+	return result, nil
 }
 
-func (r *BookRepository) GetBookByID(id int) (*models.Book, error) {
+func (r *BookRepository) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
 	start := time.Now()
 	defer func() {
 		dbQueryDuration.WithLabelValues("select", "books").Observe(time.Since(start).Seconds())
 	}()
 
 	query := `
-		SELECT id, title, author, isbn, price, published_at, created_at, updated_at
-		FROM books WHERE id = $1
+		SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+		FROM books WHERE id = $1 AND deleted_at IS NULL
 	`
 	
-	row := r.db.QueryRow(query, id)
-	var book models.Book
-	err := row.Scan(&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Price, &book.PublishedAt, &book.CreatedAt, &book.UpdatedAt)
+	row := r.db.QueryRowContext(ctx, query, id)
+	book, err := scanBook(row)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
 			dbQueryTotal.WithLabelValues("select", "books", "not_found").Inc()
-			return nil, fmt.Errorf("book with id %d not found", id)
+			return nil, fmt.Errorf("book with id %d %w", id, ErrNotFound)
 		}
 		dbQueryTotal.WithLabelValues("select", "books", "error").Inc()
 		log.Printf("Error getting book by ID %d: %v", id, err)
@@ -93,21 +150,59 @@ func (r *BookRepository) GetBookByID(id int) (*models.Book, error) {
 	
 	dbQueryTotal.WithLabelValues("select", "books", "success").Inc()
 	log.Printf("Retrieved book: ID=%d, Title=%s", book.ID, book.Title)
-	return &book, nil
+	return book, nil
+}
+
+func (r *BookRepository) GetBookByISBN(ctx context.Context, value string) (*models.Book, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("select_isbn", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	canonicalISBN, err := isbn.Normalize(value)
+	if err != nil {
+		dbQueryTotal.WithLabelValues("select_isbn", "books", "error").Inc()
+		return nil, fmt.Errorf("isbn %q: %w", value, err)
+	}
+
+	query := `
+		SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+		FROM books WHERE isbn = $1 AND deleted_at IS NULL
+	`
+
+	row := r.db.QueryRowContext(ctx, query, canonicalISBN)
+	book, err := scanBook(row)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			dbQueryTotal.WithLabelValues("select_isbn", "books", "not_found").Inc()
+			return nil, fmt.Errorf("book with isbn %s %w", canonicalISBN, ErrNotFound)
+		}
+		dbQueryTotal.WithLabelValues("select_isbn", "books", "error").Inc()
+		log.Printf("Error getting book by ISBN %s: %v", canonicalISBN, err)
+		return nil, err
+	}
+
+	dbQueryTotal.WithLabelValues("select_isbn", "books", "success").Inc()
+	log.Printf("Retrieved book: ID=%d, Title=%s", book.ID, book.Title)
+	return book, nil
 }
 
-func (r *BookRepository) GetAllBooks() ([]models.Book, error) {
+// GetAllBooks lists books newest first. Soft-deleted books are only included
+// when includeDeleted is set.
+func (r *BookRepository) GetAllBooks(ctx context.Context, includeDeleted bool) ([]models.Book, error) {
 	start := time.Now()
 	defer func() {
 		dbQueryDuration.WithLabelValues("select_all", "books").Observe(time.Since(start).Seconds())
 	}()
 
 	query := `
-		SELECT id, title, author, isbn, price, published_at, created_at, updated_at
-		FROM books ORDER BY created_at DESC
+		SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+		FROM books WHERE $1 OR deleted_at IS NULL
+		ORDER BY created_at DESC
 	`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, includeDeleted)
 	if err != nil {
 		dbQueryTotal.WithLabelValues("select_all", "books", "error").Inc()
 		log.Printf("Error getting all books: %v", err)
@@ -117,14 +212,18 @@ func (r *BookRepository) GetAllBooks() ([]models.Book, error) {
 	
 	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.ISBN, &book.Price, &book.PublishedAt, &book.CreatedAt, &book.UpdatedAt)
+		book, err := scanBook(rows)
 		if err != nil {
 			dbQueryTotal.WithLabelValues("select_all", "books", "error").Inc()
 			log.Printf("Error scanning book row: %v", err)
 			return nil, err
 		}
-		books = append(books, book)
+		books = append(books, *book)
+	}
+	if err := rows.Err(); err != nil {
+		dbQueryTotal.WithLabelValues("select_all", "books", "error").Inc()
+		log.Printf("Error iterating book rows: %v", err)
+		return nil, err
 	}
 	
 	// Ensure we return an empty slice instead of nil for consistent JSON serialization
@@ -137,47 +236,152 @@ func (r *BookRepository) GetAllBooks() ([]models.Book, error) {
 	return books, nil
 }
 
-func (r *BookRepository) UpdateBook(id int, req *models.UpdateBookRequest) (*models.Book, error) {
+// GetBooksLastModified returns when the catalog last changed: the newest
+// updated_at or deleted_at of any book, soft-deleted ones included, so that a
+// deletion moves it forward even though the row leaves the list.
+func (r *BookRepository) GetBooksLastModified(ctx context.Context) (time.Time, error) {
 	start := time.Now()
 	defer func() {
-		dbQueryDuration.WithLabelValues("update", "books").Observe(time.Since(start).Seconds())
+		dbQueryDuration.WithLabelValues("last_modified", "books").Observe(time.Since(start).Seconds())
 	}()
 
-	existing, err := r.GetBookByID(id)
+	var lastModified sql.NullTime
+	query := `SELECT GREATEST(max(updated_at), max(deleted_at)) FROM books`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&lastModified); err != nil {
+		dbQueryTotal.WithLabelValues("last_modified", "books", "error").Inc()
+		log.Printf("Error getting books last modified time: %v", err)
+		return time.Time{}, err
+	}
+
+	dbQueryTotal.WithLabelValues("last_modified", "books", "success").Inc()
+	return lastModified.Time, nil
+}
+
+// SearchBooks returns up to limit books matching filter with an ID greater
+// than afterID, ordered by ID, for keyset pagination.
+func (r *BookRepository) SearchBooks(ctx context.Context, filter models.BookFilter, afterID, limit int) ([]models.Book, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("search", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	if filter.ISBN != "" {
+		normalized, err := isbn.Normalize(filter.ISBN)
+		if err != nil {
+			dbQueryTotal.WithLabelValues("search", "books", "error").Inc()
+			return nil, err
+		}
+		filter.ISBN = normalized
+	}
+
+	query := `
+		SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+		FROM books
+		WHERE ($1 OR deleted_at IS NULL)
+		  AND ($2 = '' OR title ILIKE '%' || $2 || '%' ESCAPE '\')
+		  AND ($3 = '' OR author ILIKE '%' || $3 || '%' ESCAPE '\')
+		  AND ($4 = '' OR isbn = $4)
+		  AND id > $5
+		ORDER BY id
+		LIMIT $6
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.IncludeDeleted, escapeLike(filter.Title), escapeLike(filter.Author), filter.ISBN, afterID, limit)
 	if err != nil {
+		dbQueryTotal.WithLabelValues("search", "books", "error").Inc()
+		log.Printf("Error searching books: %v", err)
 		return nil, err
 	}
-	
-	if req.Title != nil {
-		existing.Title = *req.Title
+	defer rows.Close()
+
+	books := []models.Book{}
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			dbQueryTotal.WithLabelValues("search", "books", "error").Inc()
+			log.Printf("Error scanning book row: %v", err)
+			return nil, err
+		}
+		books = append(books, *book)
 	}
-	if req.Author != nil {
-		existing.Author = *req.Author
+	if err := rows.Err(); err != nil {
+		dbQueryTotal.WithLabelValues("search", "books", "error").Inc()
+		return nil, err
 	}
+
+	dbQueryTotal.WithLabelValues("search", "books", "success").Inc()
+	return books, nil
+}
+
+// escapeLike makes s match literally inside an ILIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *BookRepository) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("update", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	var canonicalISBN string
 	if req.ISBN != nil {
-		existing.ISBN = *req.ISBN
-	}
-	if req.Price != nil {
-		existing.Price = *req.Price
-	}
-	if req.PublishedAt != nil {
-		existing.PublishedAt = *req.PublishedAt
+		var err error
+		canonicalISBN, err = isbn.Normalize(*req.ISBN)
+		if err != nil {
+			dbQueryTotal.WithLabelValues("update", "books", "error").Inc()
+			return nil, fmt.Errorf("isbn %q: %w", *req.ISBN, err)
+		}
 	}
-	existing.UpdatedAt = time.Now()
-	
+
 	query := `
 		UPDATE books 
-		SET title = $1, author = $2, isbn = $3, price = $4, published_at = $5, updated_at = $6
-		WHERE id = $7
-		RETURNING id, title, author, isbn, price, published_at, created_at, updated_at
+		SET title = $1, author = $2, isbn = $3, price = $4, currency = $5, published_at = $6, updated_at = $7
+		WHERE id = $8 AND deleted_at IS NULL
+		RETURNING id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
 	`
-	
-	row := r.db.QueryRow(query, existing.Title, existing.Author, existing.ISBN, existing.Price, existing.PublishedAt, existing.UpdatedAt, id)
-	
-	var result models.Book
-	err = row.Scan(&result.ID, &result.Title, &result.Author, &result.ISBN, &result.Price, &result.PublishedAt, &result.CreatedAt, &result.UpdatedAt)
-	
+
+	var result *models.Book
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		existing, err := lockBook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		updated := *existing
+		if req.Title != nil {
+			updated.Title = *req.Title
+		}
+		if req.Author != nil {
+			updated.Author = *req.Author
+		}
+		if req.ISBN != nil {
+			updated.ISBN = canonicalISBN
+		}
+		if req.Price != nil {
+			updated.Price = *req.Price
+		}
+		if req.PublishedAt != nil {
+			updated.PublishedAt = *req.PublishedAt
+		}
+		updated.UpdatedAt = time.Now()
+
+		row := tx.QueryRowContext(ctx, query, updated.Title, updated.Author, updated.ISBN, updated.Price.Decimal(), updated.Price.Currency, updated.PublishedAt, updated.UpdatedAt, id)
+		if result, err = scanBook(row); err != nil {
+			return err
+		}
+		return r.recordChange(ctx, tx, auditUpdate, id, existing, result)
+	})
+
 	if err != nil {
+		if err == sql.ErrNoRows {
+			dbQueryTotal.WithLabelValues("update", "books", "not_found").Inc()
+			return nil, fmt.Errorf("book with id %d %w", id, ErrNotFound)
+		}
+		if isUniqueViolation(err) {
+			dbQueryTotal.WithLabelValues("update", "books", "conflict").Inc()
+			return nil, fmt.Errorf("isbn %s: %w", canonicalISBN, ErrDuplicateISBN)
+		}
 		dbQueryTotal.WithLabelValues("update", "books", "error").Inc()
 		log.Printf("Error updating book ID %d: %v", id, err)
 		return nil, err
@@ -185,36 +389,252 @@ func (r *BookRepository) UpdateBook(id int, req *models.UpdateBookRequest) (*mod
 	
 	dbQueryTotal.WithLabelValues("update", "books", "success").Inc()
 	log.Printf("Updated book: ID=%d, Title=%s", result.ID, result.Title)
-	return &result, nil
+	return result, nil
 }
 
-func (r *BookRepository) DeleteBook(id int) error {
+// DeleteBook soft-deletes a book by stamping deleted_at. The row stays in the
+// table until it is restored or purged after the retention period.
+func (r *BookRepository) DeleteBook(ctx context.Context, id int) error {
 	start := time.Now()
 	defer func() {
 		dbQueryDuration.WithLabelValues("delete", "books").Observe(time.Since(start).Seconds())
 	}()
 
-	query := `DELETE FROM books WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	query := `
+		UPDATE books SET deleted_at = $1, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+	`
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		existing, err := lockBook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := scanBook(tx.QueryRowContext(ctx, query, time.Now(), id))
+		if err != nil {
+			return err
+		}
+		return r.recordChange(ctx, tx, auditDelete, id, existing, deleted)
+	})
 	
 	if err != nil {
+		if err == sql.ErrNoRows {
+			dbQueryTotal.WithLabelValues("delete", "books", "not_found").Inc()
+			return fmt.Errorf("book with id %d %w", id, ErrNotFound)
+		}
 		dbQueryTotal.WithLabelValues("delete", "books", "error").Inc()
 		log.Printf("Error deleting book ID %d: %v", id, err)
 		return err
 	}
 	
-	rowsAffected, err := result.RowsAffected()
+	dbQueryTotal.WithLabelValues("delete", "books", "success").Inc()
+	log.Printf("Deleted book: ID=%d", id)
+	return nil
+}
+
+// RestoreBook clears deleted_at on a soft-deleted book.
+func (r *BookRepository) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("restore", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	query := `
+		UPDATE books SET deleted_at = NULL, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+	`
+
+	var book *models.Book
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		existing, err := scanBook(tx.QueryRowContext(ctx, `
+			SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+			FROM books WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE
+		`, id))
+		if err != nil {
+			return err
+		}
+
+		if book, err = scanBook(tx.QueryRowContext(ctx, query, time.Now(), id)); err != nil {
+			return err
+		}
+		return r.recordChange(ctx, tx, auditRestore, id, existing, book)
+	})
+
 	if err != nil {
-		dbQueryTotal.WithLabelValues("delete", "books", "error").Inc()
+		if err == sql.ErrNoRows {
+			dbQueryTotal.WithLabelValues("restore", "books", "not_found").Inc()
+			return nil, fmt.Errorf("deleted book with id %d %w", id, ErrNotFound)
+		}
+		dbQueryTotal.WithLabelValues("restore", "books", "error").Inc()
+		log.Printf("Error restoring book ID %d: %v", id, err)
+		return nil, err
+	}
+
+	dbQueryTotal.WithLabelValues("restore", "books", "success").Inc()
+	log.Printf("Restored book: ID=%d, Title=%s", book.ID, book.Title)
+	return book, nil
+}
+
+// PurgeDeletedBooks permanently removes books soft-deleted before cutoff and
+// returns the number of rows removed. Every removed book gets a purge entry in
+// its history and, with the outbox enabled, a book.purged message.
+func (r *BookRepository) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int64, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("purge", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	query := `
+		DELETE FROM books WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		RETURNING id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+	`
+
+	var purged []*models.Book
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			book, err := scanBook(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			purged = append(purged, book)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// The rows must be read to the end before the transaction can run
+		// the audit and outbox inserts
+		for _, book := range purged {
+			if err := r.recordChange(ctx, tx, auditPurge, book.ID, book, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		dbQueryTotal.WithLabelValues("purge", "books", "error").Inc()
+		log.Printf("Error purging deleted books: %v", err)
+		return 0, err
+	}
+
+	dbQueryTotal.WithLabelValues("purge", "books", "success").Inc()
+	return int64(len(purged)), nil
+}
+
+// StreamBooks iterates over every book ordered by ID, invoking fn for each row
+// without materializing the full result set.
+func (r *BookRepository) StreamBooks(ctx context.Context, fn func(*models.Book) error) error {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("export", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	query := `
+		SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+		FROM books WHERE deleted_at IS NULL
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		dbQueryTotal.WithLabelValues("export", "books", "error").Inc()
+		log.Printf("Error exporting books: %v", err)
 		return err
 	}
-	
-	if rowsAffected == 0 {
-		dbQueryTotal.WithLabelValues("delete", "books", "not_found").Inc()
-		return fmt.Errorf("book with id %d not found", id)
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			dbQueryTotal.WithLabelValues("export", "books", "error").Inc()
+			log.Printf("Error scanning book row: %v", err)
+			return err
+		}
+		if err := fn(book); err != nil {
+			dbQueryTotal.WithLabelValues("export", "books", "error").Inc()
+			return err
+		}
+		count++
 	}
-	
-	dbQueryTotal.WithLabelValues("delete", "books", "success").Inc()
-	log.Printf("Deleted book: ID=%d", id)
+	if err := rows.Err(); err != nil {
+		dbQueryTotal.WithLabelValues("export", "books", "error").Inc()
+		log.Printf("Error iterating book rows: %v", err)
+		return err
+	}
+
+	dbQueryTotal.WithLabelValues("export", "books", "success").Inc()
+	log.Printf("Exported %d books", count)
 	return nil
-}
\ No newline at end of file
+}
+
+// UpsertBookByISBN inserts the book or, when a book with the same ISBN already
+// exists, overwrites it (restoring it if it was soft-deleted). The returned flag
+// reports whether a new row was created.
+func (r *BookRepository) UpsertBookByISBN(ctx context.Context, book *models.CreateBookRequest) (*models.Book, bool, error) {
+	start := time.Now()
+	defer func() {
+		dbQueryDuration.WithLabelValues("upsert", "books").Observe(time.Since(start).Seconds())
+	}()
+
+	query := `
+		INSERT INTO books (title, author, isbn, price, currency, published_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (isbn) DO UPDATE
+		SET title = EXCLUDED.title, author = EXCLUDED.author, price = EXCLUDED.price,
+			currency = EXCLUDED.currency, published_at = EXCLUDED.published_at, updated_at = EXCLUDED.updated_at,
+			deleted_at = NULL
+		RETURNING id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at, (xmax = 0) AS inserted
+	`
+
+	canonicalISBN, err := isbn.Normalize(book.ISBN)
+	if err != nil {
+		dbQueryTotal.WithLabelValues("upsert", "books", "error").Inc()
+		return nil, false, fmt.Errorf("isbn %q: %w", book.ISBN, err)
+	}
+
+	now := time.Now()
+	var result *models.Book
+	var inserted bool
+	err = r.withTx(ctx, func(tx *sql.Tx) error {
+		existing, err := scanBook(tx.QueryRowContext(ctx, `
+			SELECT id, title, author, isbn, price, currency, published_at, created_at, updated_at, deleted_at
+			FROM books WHERE isbn = $1 FOR UPDATE
+		`, canonicalISBN))
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		row := tx.QueryRowContext(ctx, query, book.Title, book.Author, canonicalISBN, book.Price.Decimal(), book.Price.Currency, book.PublishedAt, now, now)
+		if result, err = scanBook(row, &inserted); err != nil {
+			return err
+		}
+		if inserted {
+			return r.recordChange(ctx, tx, auditCreate, result.ID, nil, result)
+		}
+		// Importing the ISBN of a soft-deleted book brings it back, which the
+		// history shows as a restore like POST /books/{id}/restore
+		if existing.DeletedAt != nil {
+			return r.recordChange(ctx, tx, auditRestore, result.ID, existing, result)
+		}
+		return r.recordChange(ctx, tx, auditUpdate, result.ID, existing, result)
+	})
+
+	if err != nil {
+		dbQueryTotal.WithLabelValues("upsert", "books", "error").Inc()
+		log.Printf("Error upserting book ISBN %s: %v", book.ISBN, err)
+		return nil, false, err
+	}
+
+	dbQueryTotal.WithLabelValues("upsert", "books", "success").Inc()
+	return result, inserted, nil
+}
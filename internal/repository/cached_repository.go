@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin-prometheus-grafana/internal/cache"
+	"gin-prometheus-grafana/internal/models"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+// bookCacheName labels the metrics of the book cache.
+const bookCacheName = "books"
+
+var cacheRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Total number of cache lookups by cache and result (hit, miss, stale served on error)",
+	},
+	[]string{"cache", "result"},
+)
+
+// CachedBookRepository is a read-through cache in front of a BookStore for
+// single-book lookups. Concurrent misses for the same book share one query,
+// and writes invalidate the cached entry. An expired entry is reloaded, but
+// is still served if the backing store fails.
+type CachedBookRepository struct {
+	BookStore
+	cache cache.Cache
+	group singleflight.Group
+
+	// loads tracks the keys being loaded. A write bumps the key's generation,
+	// and a load that read the row before the write sees the change and does
+	// not put its now stale copy in the cache.
+	mu    sync.Mutex
+	loads map[string]*loadState
+}
+
+type loadState struct {
+	generation uint64
+	inFlight   int
+}
+
+func NewCachedBookRepository(store BookStore, c cache.Cache) *CachedBookRepository {
+	return &CachedBookRepository{BookStore: store, cache: c, loads: make(map[string]*loadState)}
+}
+
+func bookCacheKey(id int) string {
+	return "book:" + strconv.Itoa(id)
+}
+
+func (r *CachedBookRepository) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	key := bookCacheKey(id)
+
+	var cached *models.Book
+	if data, stale, ok := r.cache.Get(ctx, key); ok {
+		var book models.Book
+		if err := json.Unmarshal(data, &book); err != nil {
+			log.Printf("Discarding undecodable cache entry %s", key)
+		} else if !stale {
+			cacheRequestsTotal.WithLabelValues(bookCacheName, "hit").Inc()
+			return &book, nil
+		} else {
+			cached = &book
+		}
+	}
+
+	// The shared load must not be cancelled just because the request that
+	// happened to start it goes away.
+	loadCtx := context.WithoutCancel(ctx)
+	v, err, _ := r.group.Do(key, func() (interface{}, error) {
+		generation := r.beginLoad(key)
+		book, err := r.BookStore.GetBookByID(loadCtx, id)
+		r.endLoad(key, generation, func() {
+			if err != nil {
+				return
+			}
+			if encoded, err := json.Marshal(book); err == nil {
+				r.cache.Set(loadCtx, key, encoded)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		return book, nil
+	})
+	if err != nil {
+		// An expired copy is better than an error while the database is
+		// unavailable, but not once the book is gone
+		if cached != nil && !errors.Is(err, ErrNotFound) {
+			log.Printf("Serving stale cache entry %s: %v", key, err)
+			cacheRequestsTotal.WithLabelValues(bookCacheName, "stale").Inc()
+			return cached, nil
+		}
+		cacheRequestsTotal.WithLabelValues(bookCacheName, "miss").Inc()
+		return nil, err
+	}
+	cacheRequestsTotal.WithLabelValues(bookCacheName, "miss").Inc()
+
+	// Callers sharing a load each get their own copy.
+	book := *v.(*models.Book)
+	return &book, nil
+}
+
+func (r *CachedBookRepository) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	book, err := r.BookStore.UpdateBook(ctx, id, req)
+	if err == nil {
+		r.invalidate(ctx, id)
+	}
+	return book, err
+}
+
+func (r *CachedBookRepository) DeleteBook(ctx context.Context, id int) error {
+	err := r.BookStore.DeleteBook(ctx, id)
+	if err == nil {
+		r.invalidate(ctx, id)
+	}
+	return err
+}
+
+func (r *CachedBookRepository) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	book, err := r.BookStore.RestoreBook(ctx, id)
+	if err == nil {
+		r.invalidate(ctx, id)
+	}
+	return book, err
+}
+
+func (r *CachedBookRepository) UpsertBookByISBN(ctx context.Context, req *models.CreateBookRequest) (*models.Book, bool, error) {
+	book, created, err := r.BookStore.UpsertBookByISBN(ctx, req)
+	if err == nil && !created {
+		r.invalidate(ctx, book.ID)
+	}
+	return book, created, err
+}
+
+// invalidate drops the cached book. Bumping the generation before the delete
+// means a load in flight either filled the cache first, and the delete
+// removes its copy, or sees the bump and skips the fill.
+func (r *CachedBookRepository) invalidate(ctx context.Context, id int) {
+	key := bookCacheKey(id)
+	r.mu.Lock()
+	if state, ok := r.loads[key]; ok {
+		state.generation++
+	}
+	r.mu.Unlock()
+	r.group.Forget(key)
+	r.cache.Delete(ctx, key)
+}
+
+func (r *CachedBookRepository) beginLoad(key string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.loads[key]
+	if !ok {
+		state = &loadState{}
+		r.loads[key] = state
+	}
+	state.inFlight++
+	return state.generation
+}
+
+// endLoad runs fill if no write invalidated key since beginLoad returned
+// generation. fill runs under mu so that it cannot interleave with invalidate.
+func (r *CachedBookRepository) endLoad(key string, generation uint64, fill func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.loads[key]
+	if state.generation == generation {
+		fill()
+	}
+	if state.inFlight--; state.inFlight == 0 {
+		delete(r.loads, key)
+	}
+}
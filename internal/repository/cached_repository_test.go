@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gin-prometheus-grafana/internal/cache"
+	"gin-prometheus-grafana/internal/models"
+)
+
+// titleStore holds the title of every book and counts lookups. With release
+// set, the next lookup reads the title, signals loading and then waits for
+// release before returning it.
+type titleStore struct {
+	BookStore
+
+	mu      sync.Mutex
+	title   string
+	err     error
+	lookups int
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (s *titleStore) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	s.mu.Lock()
+	s.lookups++
+	title, err, release := s.title, s.err, s.release
+	s.release = nil
+	s.mu.Unlock()
+
+	if release != nil {
+		s.loading <- struct{}{}
+		<-release
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.Book{ID: id, Title: title}, nil
+}
+
+func (s *titleStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.title = *req.Title
+	return &models.Book{ID: id, Title: s.title}, nil
+}
+
+func (s *titleStore) set(title string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.title, s.err = title, err
+}
+
+func getTitle(t *testing.T, r *CachedBookRepository) string {
+	t.Helper()
+	book, err := r.GetBookByID(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book.Title
+}
+
+func TestCachedBookRepositoryServesHits(t *testing.T) {
+	store := &titleStore{title: "Dune"}
+	r := NewCachedBookRepository(store, cache.NewLRU("test", 10, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		if got := getTitle(t, r); got != "Dune" {
+			t.Fatalf("title = %q, want %q", got, "Dune")
+		}
+	}
+	if store.lookups != 1 {
+		t.Errorf("store lookups = %d, want 1", store.lookups)
+	}
+
+	title := "Dune Messiah"
+	if _, err := r.UpdateBook(context.Background(), 1, &models.UpdateBookRequest{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getTitle(t, r); got != title {
+		t.Errorf("title after update = %q, want %q", got, title)
+	}
+}
+
+func TestCachedBookRepositoryServesStaleOnError(t *testing.T) {
+	store := &titleStore{title: "Dune"}
+	// Every entry is expired as soon as it is stored
+	r := NewCachedBookRepository(store, cache.NewLRU("test", 10, -time.Second))
+	getTitle(t, r)
+
+	store.set("", errors.New("connection refused"))
+	if got := getTitle(t, r); got != "Dune" {
+		t.Errorf("title while the store fails = %q, want the stale %q", got, "Dune")
+	}
+
+	store.set("", fmt.Errorf("book with id 1 %w", ErrNotFound))
+	if _, err := r.GetBookByID(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("error for a deleted book = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCachedBookRepositoryUpdateDuringLoad(t *testing.T) {
+	release := make(chan struct{})
+	store := &titleStore{title: "Dune", loading: make(chan struct{}), release: release}
+	r := NewCachedBookRepository(store, cache.NewLRU("test", 10, time.Minute))
+
+	loaded := make(chan string)
+	go func() {
+		book, err := r.GetBookByID(context.Background(), 1)
+		if err != nil {
+			loaded <- err.Error()
+			return
+		}
+		loaded <- book.Title
+	}()
+
+	// The load has read the old title; the update commits before it finishes
+	<-store.loading
+	title := "Dune Messiah"
+	if _, err := r.UpdateBook(context.Background(), 1, &models.UpdateBookRequest{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if got := <-loaded; got != "Dune" {
+		t.Fatalf("racing load = %q, want %q", got, "Dune")
+	}
+
+	// The old title must not have been cached over the update
+	if got := getTitle(t, r); got != title {
+		t.Errorf("title after update = %q, want %q", got, title)
+	}
+	if store.lookups != 2 {
+		t.Errorf("store lookups = %d, want 2", store.lookups)
+	}
+}
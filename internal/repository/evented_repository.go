@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"gin-prometheus-grafana/internal/events"
+	"gin-prometheus-grafana/internal/models"
+)
+
+// EventedBookStore announces every successful write to a BookStore on an
+// events.Bus. Publishing here rather than in the REST handlers means changes
+// made through GraphQL, gRPC and bulk import reach live subscribers too.
+type EventedBookStore struct {
+	BookStore
+	bus *events.Bus
+}
+
+// NewEventedBookStore wraps store so that its writes are published on bus.
+func NewEventedBookStore(store BookStore, bus *events.Bus) *EventedBookStore {
+	return &EventedBookStore{BookStore: store, bus: bus}
+}
+
+func (s *EventedBookStore) CreateBook(ctx context.Context, req *models.CreateBookRequest) (*models.Book, error) {
+	book, err := s.BookStore.CreateBook(ctx, req)
+	if err == nil {
+		s.bus.Publish(ctx, events.Created, book.ID, book)
+	}
+	return book, err
+}
+
+func (s *EventedBookStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	book, err := s.BookStore.UpdateBook(ctx, id, req)
+	if err == nil {
+		s.bus.Publish(ctx, events.Updated, book.ID, book)
+	}
+	return book, err
+}
+
+func (s *EventedBookStore) DeleteBook(ctx context.Context, id int) error {
+	err := s.BookStore.DeleteBook(ctx, id)
+	if err == nil {
+		s.bus.Publish(ctx, events.Deleted, id, nil)
+	}
+	return err
+}
+
+func (s *EventedBookStore) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	book, err := s.BookStore.RestoreBook(ctx, id)
+	if err == nil {
+		s.bus.Publish(ctx, events.Updated, book.ID, book)
+	}
+	return book, err
+}
+
+func (s *EventedBookStore) UpsertBookByISBN(ctx context.Context, req *models.CreateBookRequest) (*models.Book, bool, error) {
+	book, created, err := s.BookStore.UpsertBookByISBN(ctx, req)
+	if err == nil {
+		typ := events.Updated
+		if created {
+			typ = events.Created
+		}
+		s.bus.Publish(ctx, typ, book.ID, book)
+	}
+	return book, created, err
+}
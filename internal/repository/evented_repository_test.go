@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gin-prometheus-grafana/internal/events"
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/reqctx"
+)
+
+// stubStore succeeds for book 1 and fails for every other ID.
+type stubStore struct {
+	BookStore
+}
+
+var errStub = errors.New("not found")
+
+func (stubStore) CreateBook(ctx context.Context, req *models.CreateBookRequest) (*models.Book, error) {
+	return &models.Book{ID: 1, Title: req.Title}, nil
+}
+
+func (stubStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error) {
+	if id != 1 {
+		return nil, errStub
+	}
+	return &models.Book{ID: id}, nil
+}
+
+func (stubStore) DeleteBook(ctx context.Context, id int) error {
+	if id != 1 {
+		return errStub
+	}
+	return nil
+}
+
+func (stubStore) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	return &models.Book{ID: id}, nil
+}
+
+func (stubStore) UpsertBookByISBN(ctx context.Context, req *models.CreateBookRequest) (*models.Book, bool, error) {
+	return &models.Book{ID: 2, ISBN: req.ISBN}, req.ISBN == "new", nil
+}
+
+func TestEventedBookStorePublishesWrites(t *testing.T) {
+	bus := events.NewBus(10, 10)
+	store := NewEventedBookStore(stubStore{}, bus)
+	ctx := reqctx.WithActor(context.Background(), "alice")
+
+	store.CreateBook(ctx, &models.CreateBookRequest{Title: "Dune"})
+	store.UpdateBook(ctx, 1, &models.UpdateBookRequest{})
+	store.UpdateBook(ctx, 9, &models.UpdateBookRequest{})
+	store.DeleteBook(ctx, 1)
+	store.DeleteBook(ctx, 9)
+	store.RestoreBook(ctx, 1)
+	store.UpsertBookByISBN(ctx, &models.CreateBookRequest{ISBN: "new"})
+	store.UpsertBookByISBN(ctx, &models.CreateBookRequest{ISBN: "old"})
+
+	sub, replay, _ := bus.Subscribe(0, true)
+	sub.Close()
+	want := []string{events.Created, events.Updated, events.Deleted, events.Updated, events.Created, events.Updated}
+	if len(replay) != len(want) {
+		t.Fatalf("published %d events, want %d: %+v", len(replay), len(want), replay)
+	}
+	for i, e := range replay {
+		if e.Type != want[i] || e.Actor != "alice" {
+			t.Errorf("event %d = %s by %q, want %s by alice", i, e.Type, e.Actor, want[i])
+		}
+	}
+}
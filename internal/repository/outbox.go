@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gin-prometheus-grafana/internal/events"
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/outbox"
+	"gin-prometheus-grafana/internal/reqctx"
+	"strconv"
+	"time"
+)
+
+// outboxEventTypes maps audit operations to the change announced in the
+// outbox. A restore makes the book visible again, so it is an update. A purge
+// has its own type, as its book was already announced deleted; live
+// subscribers are not told, since purges do not go through the events bus.
+var outboxEventTypes = map[string]string{
+	auditCreate:  events.Created,
+	auditUpdate:  events.Updated,
+	auditDelete:  events.Deleted,
+	auditRestore: events.Updated,
+	auditPurge:   outboxPurged,
+}
+
+// outboxPurged is the outbox type of a permanently removed book.
+const outboxPurged = "purged"
+
+// bookChange is the outbox payload, published on topic "book.<type>".
+type bookChange struct {
+	Type      string       `json:"type"`
+	BookID    int          `json:"book_id"`
+	Book      *models.Book `json:"book"`
+	Actor     string       `json:"actor,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Time      time.Time    `json:"time"`
+}
+
+// EnableOutbox makes every write also queue a change message in the outbox
+// table, in the same transaction, once for each consumer's outbox.Relay to
+// publish. Call it before the repository is shared.
+func (r *BookRepository) EnableOutbox(consumers ...string) {
+	r.outboxConsumers = consumers
+}
+
+// recordChange writes the audit entry for a mutation and, when the outbox is
+// enabled, the message announcing it.
+func (r *BookRepository) recordChange(ctx context.Context, tx *sql.Tx, operation string, bookID int, before, after *models.Book) error {
+	if err := writeAudit(ctx, tx, operation, bookID, before, after); err != nil {
+		return err
+	}
+	if len(r.outboxConsumers) == 0 {
+		return nil
+	}
+
+	typ := outboxEventTypes[operation]
+	change := bookChange{
+		Type:      typ,
+		BookID:    bookID,
+		Book:      after,
+		Actor:     reqctx.Actor(ctx),
+		RequestID: reqctx.RequestID(ctx),
+		Time:      time.Now().UTC(),
+	}
+	if err := outbox.Insert(ctx, tx, r.outboxConsumers, "book."+typ, strconv.Itoa(bookID), change); err != nil {
+		dbQueryTotal.WithLabelValues("insert", "outbox", "error").Inc()
+		return err
+	}
+	dbQueryTotal.WithLabelValues("insert", "outbox", "success").Inc()
+	return nil
+}
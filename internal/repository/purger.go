@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var booksPurgedTotal = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "books_purged_total",
+		Help: "Total number of soft-deleted books permanently removed by the purger",
+	},
+)
+
+// Purger periodically removes books that have been soft-deleted for longer
+// than the retention period.
+type Purger struct {
+	repo      *BookRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(repo *BookRepository, retention, interval time.Duration) *Purger {
+	return &Purger{repo: repo, retention: retention, interval: interval}
+}
+
+// Run purges once immediately and then on every interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	purged, err := p.repo.PurgeDeletedBooks(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("Failed to purge deleted books: %v", err)
+		return
+	}
+
+	booksPurgedTotal.Add(float64(purged))
+	if purged > 0 {
+		log.Printf("Purged %d books deleted more than %s ago", purged, p.retention)
+	}
+}
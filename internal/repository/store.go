@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gin-prometheus-grafana/internal/models"
+	"time"
+)
+
+var (
+	// ErrNotFound is wrapped by the errors of lookups and writes whose book
+	// or webhook does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicateISBN is returned when a create or update would give a book
+	// the ISBN of another book, soft-deleted ones included. ISBNs are
+	// compared in their normalized ISBN-13 form.
+	ErrDuplicateISBN = errors.New("a book with this ISBN already exists")
+)
+
+// BookStore is the set of book operations used by the HTTP handlers. It is
+// implemented by BookRepository and by decorators such as CachedBookRepository.
+type BookStore interface {
+	CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error)
+	GetBookByID(ctx context.Context, id int) (*models.Book, error)
+	GetBookByISBN(ctx context.Context, value string) (*models.Book, error)
+	GetAllBooks(ctx context.Context, includeDeleted bool) ([]models.Book, error)
+	GetBooksLastModified(ctx context.Context) (time.Time, error)
+	SearchBooks(ctx context.Context, filter models.BookFilter, afterID, limit int) ([]models.Book, error)
+	UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest) (*models.Book, error)
+	DeleteBook(ctx context.Context, id int) error
+	RestoreBook(ctx context.Context, id int) (*models.Book, error)
+	GetBookHistory(ctx context.Context, id int) (*models.BookHistory, error)
+	StreamBooks(ctx context.Context, fn func(*models.Book) error) error
+	UpsertBookByISBN(ctx context.Context, book *models.CreateBookRequest) (*models.Book, bool, error)
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gin-prometheus-grafana/internal/models"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebhookRepository stores webhook subscriptions and the log of deliveries
+// made to them.
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// ClaimedDelivery is a pending delivery leased to a deliverer, with what it
+// needs to send it.
+type ClaimedDelivery struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// observe records one query against table the same way BookRepository does.
+func observe(op, table string, start time.Time, err error) {
+	dbQueryDuration.WithLabelValues(op, table).Observe(time.Since(start).Seconds())
+	switch {
+	case err == sql.ErrNoRows:
+		dbQueryTotal.WithLabelValues(op, table, "not_found").Inc()
+	case err != nil:
+		dbQueryTotal.WithLabelValues(op, table, "error").Inc()
+	default:
+		dbQueryTotal.WithLabelValues(op, table, "success").Inc()
+	}
+}
+
+func scanWebhook(row rowScanner, withSecret bool) (*models.Webhook, error) {
+	var w models.Webhook
+	var secret string
+	if err := row.Scan(&w.ID, &w.URL, pq.Array(&w.EventTypes), &secret, &w.Active, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if withSecret {
+		w.Secret = secret
+	}
+	return &w, nil
+}
+
+const webhookColumns = `id, url, event_types, secret, active, created_at, updated_at`
+
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	start := time.Now()
+	query := `
+		INSERT INTO webhooks (url, event_types, secret)
+		VALUES ($1, $2, $3)
+		RETURNING ` + webhookColumns
+	w, err := scanWebhook(r.db.QueryRowContext(ctx, query, req.URL, pq.Array(req.EventTypes), req.Secret), true)
+	observe("insert", "webhooks", start, err)
+	if err != nil {
+		log.Printf("Error creating webhook: %v", err)
+		return nil, err
+	}
+	log.Printf("Created webhook: ID=%d, URL=%s", w.ID, w.URL)
+	return w, nil
+}
+
+func (r *WebhookRepository) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	start := time.Now()
+	rows, err := r.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		observe("select", "webhooks", start, err)
+		log.Printf("Error listing webhooks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows, false)
+		if err != nil {
+			observe("select", "webhooks", start, err)
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	err = rows.Err()
+	observe("select", "webhooks", start, err)
+	return webhooks, err
+}
+
+func (r *WebhookRepository) GetWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	start := time.Now()
+	w, err := scanWebhook(r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id), false)
+	observe("select", "webhooks", start, err)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook with id %d %w", id, ErrNotFound)
+	}
+	return w, err
+}
+
+func (r *WebhookRepository) UpdateWebhook(ctx context.Context, id int64, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	start := time.Now()
+	var eventTypes interface{}
+	if len(req.EventTypes) > 0 {
+		eventTypes = pq.Array(req.EventTypes)
+	}
+	query := `
+		UPDATE webhooks SET
+			url = COALESCE($1, url),
+			event_types = COALESCE($2, event_types),
+			secret = COALESCE($3, secret),
+			active = COALESCE($4, active),
+			updated_at = now()
+		WHERE id = $5
+		RETURNING ` + webhookColumns
+	w, err := scanWebhook(r.db.QueryRowContext(ctx, query, req.URL, eventTypes, req.Secret, req.Active, id), false)
+	observe("update", "webhooks", start, err)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		log.Printf("Error updating webhook ID %d: %v", id, err)
+		return nil, err
+	}
+	return w, nil
+}
+
+// DeleteWebhook removes a webhook together with its delivery log.
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	start := time.Now()
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	observe("delete", "webhooks", start, err)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("webhook with id %d %w", id, ErrNotFound)
+	}
+	return err
+}
+
+const deliveryColumns = `id, webhook_id, message_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	var next time.Time
+	dest := append([]interface{}{&d.ID, &d.WebhookID, &d.MessageID, &d.EventType, &payload, &d.Status, &d.Attempts, &next,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	if d.Status == models.DeliveryPending {
+		d.NextAttemptAt = &next
+	}
+	return &d, nil
+}
+
+// ListDeliveries returns a webhook's most recent deliveries, newest first,
+// optionally only those in one status.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]models.WebhookDelivery, error) {
+	start := time.Now()
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, webhookID, status, limit)
+	if err != nil {
+		observe("select", "webhook_deliveries", start, err)
+		log.Printf("Error listing deliveries for webhook ID %d: %v", webhookID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			observe("select", "webhook_deliveries", start, err)
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	err = rows.Err()
+	observe("select", "webhook_deliveries", start, err)
+	return deliveries, err
+}
+
+// RedeliverDelivery queues a delivery to be sent again right away, which is
+// how dead deliveries are replayed once the receiver is fixed.
+func (r *WebhookRepository) RedeliverDelivery(ctx context.Context, webhookID, deliveryID int64) (*models.WebhookDelivery, error) {
+	start := time.Now()
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING ` + deliveryColumns
+	d, err := scanDelivery(r.db.QueryRowContext(ctx, query, deliveryID, webhookID))
+	observe("update", "webhook_deliveries", start, err)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("delivery with id %d %w", deliveryID, ErrNotFound)
+	}
+	return d, err
+}
+
+// EnqueueDeliveries creates a pending delivery of a message for every active
+// webhook subscribed to its event type. Enqueueing the same message again is
+// a no-op, so it is safe to repeat after a failure.
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, messageID int64, eventType string, payload []byte) (int, error) {
+	start := time.Now()
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, message_id, event_type, payload)
+		SELECT id, $1, $2, $3 FROM webhooks
+		WHERE active AND $2 = ANY(event_types)
+		ON CONFLICT (webhook_id, message_id) DO NOTHING
+	`
+	res, err := r.db.ExecContext(ctx, query, messageID, eventType, string(payload))
+	observe("insert", "webhook_deliveries", start, err)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// ClaimDeliveries leases up to limit due deliveries of active webhooks by
+// pushing their next attempt lease into the future. A deliverer that dies
+// mid-send leaves the delivery to be retried once the lease runs out.
+func (r *WebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	start := time.Now()
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $2::double precision * interval '1 second'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT pending.id FROM webhook_deliveries pending
+			JOIN webhooks active ON active.id = pending.webhook_id AND active.active
+			WHERE pending.status = 'pending' AND pending.next_attempt_at <= now()
+			ORDER BY pending.next_attempt_at, pending.id
+			LIMIT $1
+			FOR UPDATE OF pending SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.message_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.last_status_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		observe("claim", "webhook_deliveries", start, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []ClaimedDelivery
+	for rows.Next() {
+		var c ClaimedDelivery
+		d, err := scanDelivery(rows, &c.URL, &c.Secret)
+		if err != nil {
+			observe("claim", "webhook_deliveries", start, err)
+			return nil, err
+		}
+		c.WebhookDelivery = *d
+		claimed = append(claimed, c)
+	}
+	err = rows.Err()
+	observe("claim", "webhook_deliveries", start, err)
+	return claimed, err
+}
+
+// RecordDeliveryAttempt stores the outcome of one attempt. A failed attempt
+// is retried after retryAfter, or marks the delivery dead when dead is set.
+func (r *WebhookRepository) RecordDeliveryAttempt(ctx context.Context, id int64, statusCode int, attemptErr error, retryAfter time.Duration, dead bool) error {
+	start := time.Now()
+	var code *int
+	if statusCode > 0 {
+		code = &statusCode
+	}
+	var query string
+	var args []interface{}
+	switch {
+	case attemptErr == nil:
+		query = `UPDATE webhook_deliveries SET status = 'succeeded', attempts = attempts + 1, last_status_code = $1, last_error = NULL, delivered_at = now() WHERE id = $2`
+		args = []interface{}{code, id}
+	case dead:
+		query = `UPDATE webhook_deliveries SET status = 'dead', attempts = attempts + 1, last_status_code = $1, last_error = $2 WHERE id = $3`
+		args = []interface{}{code, attemptErr.Error(), id}
+	default:
+		query = `
+			UPDATE webhook_deliveries SET attempts = attempts + 1, last_status_code = $1, last_error = $2,
+				next_attempt_at = now() + $3::double precision * interval '1 second'
+			WHERE id = $4
+		`
+		args = []interface{}{code, attemptErr.Error(), retryAfter.Seconds(), id}
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	observe("update", "webhook_deliveries", start, err)
+	return err
+}
@@ -0,0 +1,42 @@
+// Package reqctx carries per-request metadata, such as the request ID and the
+// acting principal, through context.Context so that layers below the HTTP
+// handlers (e.g. the repository audit log) can read it.
+package reqctx
+
+import "context"
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	actorKey
+)
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// MaxActorLength is the size of the audit log's actor column.
+const MaxActorLength = 255
+
+// WithActor stores actor in ctx, truncated to MaxActorLength characters so
+// that an oversized X-Actor header or token subject cannot fail the write it
+// is audited with.
+func WithActor(ctx context.Context, actor string) context.Context {
+	if r := []rune(actor); len(r) > MaxActorLength {
+		actor = string(r[:MaxActorLength])
+	}
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the identity performing the request, or "" if unknown.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
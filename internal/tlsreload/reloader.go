@@ -0,0 +1,235 @@
+// Package tlsreload serves TLS certificates that are reloaded from disk when
+// the files change, so certificates can be rotated without a restart.
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	certificateExpiry = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tls_certificate_expiry_timestamp_seconds",
+			Help: "Expiry time of the loaded TLS certificates as a Unix timestamp (earliest in the bundle for CAs)",
+		},
+		[]string{"certificate"},
+	)
+
+	certificateReloadsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tls_certificate_reloads_total",
+			Help: "Total number of TLS certificate reloads by status",
+		},
+		[]string{"status"},
+	)
+)
+
+// Config names the files to serve. ClientCAFile is optional; when set, client
+// certificates are verified against it.
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	// RequireClientCert rejects handshakes without a client certificate.
+	// Otherwise one is verified only if presented.
+	RequireClientCert bool
+}
+
+// Reloader holds the current certificate and client CA pool.
+type Reloader struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	stamps   map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New loads the configured files, failing if any of them is unusable.
+func New(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+	r := &Reloader{cfg: cfg}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a server configuration that always hands out the most
+// recently loaded certificate and client CA pool.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: r.getCertificate,
+	}
+	if r.cfg.ClientCAFile == "" {
+		return base
+	}
+
+	base.ClientAuth = tls.VerifyClientCertIfGiven
+	if r.cfg.RequireClientCert {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	cfg := base.Clone()
+	// The CA pool is fixed per tls.Config, so hand each handshake a copy with
+	// the current one
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		perConn := base.Clone()
+		r.mu.RLock()
+		perConn.ClientCAs = r.clientCA
+		r.mu.RUnlock()
+		return perConn, nil
+	}
+	return cfg
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Run checks the files every interval and reloads them when they change. A
+// failed reload is logged and the previous certificates stay in use.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				certificateReloadsTotal.WithLabelValues("error").Inc()
+				log.Printf("Failed to reload TLS certificates, keeping the current ones: %v", err)
+				continue
+			}
+			certificateReloadsTotal.WithLabelValues("success").Inc()
+			log.Printf("Reloaded TLS certificate from %s", r.cfg.CertFile)
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// Mid-rotation; try again on the next tick
+			return false
+		}
+		if s := r.stamps[f]; !s.modTime.Equal(info.ModTime()) || s.size != info.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) load() error {
+	stamps := make(map[string]fileStamp)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		stamps[f] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parse certificate %s: %v", r.cfg.CertFile, err)
+	}
+	cert.Leaf = leaf
+
+	var pool *x509.CertPool
+	var caExpiry time.Time
+	if r.cfg.ClientCAFile != "" {
+		pool, caExpiry, err = loadCertPool(r.cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.stamps = stamps
+	r.mu.Unlock()
+
+	certificateExpiry.WithLabelValues("server").Set(float64(leaf.NotAfter.Unix()))
+	if pool != nil {
+		certificateExpiry.WithLabelValues("client_ca").Set(float64(caExpiry.Unix()))
+	}
+	return nil
+}
+
+// loadCertPool parses a PEM bundle of CA certificates and returns the pool with
+// the earliest expiry among them.
+func loadCertPool(path string) (*x509.CertPool, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	pool := x509.NewCertPool()
+	var earliest time.Time
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("parse CA bundle %s: %v", path, err)
+		}
+		pool.AddCert(ca)
+		if earliest.IsZero() || ca.NotAfter.Before(earliest) {
+			earliest = ca.NotAfter
+		}
+	}
+	if earliest.IsZero() {
+		return nil, time.Time{}, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, earliest, nil
+}
@@ -0,0 +1,214 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gin-prometheus-grafana/internal/repository"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	webhookDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts by event type and outcome",
+		},
+		[]string{"event", "outcome"},
+	)
+
+	webhookDeliveryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "webhook_delivery_duration_seconds",
+			Help: "Duration of webhook delivery HTTP requests in seconds",
+		},
+		[]string{"outcome"},
+	)
+
+	webhookDeliveryLatency = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "webhook_delivery_latency_seconds",
+			Help:    "Time from a book change to its successful webhook delivery in seconds",
+			Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600, 14400},
+		},
+	)
+)
+
+// DeliveryStore is the storage a Deliverer works against; it is implemented
+// by repository.WebhookRepository.
+type DeliveryStore interface {
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.ClaimedDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, id int64, statusCode int, attemptErr error, retryAfter time.Duration, dead bool) error
+}
+
+// DelivererConfig configures a Deliverer.
+type DelivererConfig struct {
+	// PollInterval is the pause between polls when nothing is due.
+	PollInterval time.Duration
+	// BatchSize is the most deliveries claimed per poll, and Concurrency how
+	// many of them are sent at once.
+	BatchSize   int
+	Concurrency int
+	// Timeout bounds each HTTP request.
+	Timeout time.Duration
+	// A failed delivery is retried after BaseBackoff, doubling with every
+	// further failure up to MaxBackoff, and marked dead after MaxAttempts.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// AllowPrivateTargets lets deliveries connect to loopback, private and
+	// link-local addresses; see TargetGuard.
+	AllowPrivateTargets bool
+}
+
+var DefaultDelivererConfig = DelivererConfig{
+	PollInterval: time.Second,
+	BatchSize:    50,
+	Concurrency:  8,
+	Timeout:      10 * time.Second,
+	MaxAttempts:  8,
+	BaseBackoff:  10 * time.Second,
+	MaxBackoff:   time.Hour,
+}
+
+// Deliverer sends pending deliveries. Several replicas may run one against
+// the same tables: each claims deliveries under a lease, so a delivery is
+// only in flight on one of them at a time.
+type Deliverer struct {
+	store  DeliveryStore
+	client *http.Client
+	cfg    DelivererConfig
+}
+
+func NewDeliverer(store DeliveryStore, cfg DelivererConfig) *Deliverer {
+	guard := TargetGuard{AllowPrivate: cfg.AllowPrivateTargets}
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: guard.control}
+	client := &http.Client{
+		// No proxy, so that the guard checks the receiver's address rather
+		// than the proxy's.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConnsPerHost: max(cfg.Concurrency, 2),
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		Timeout: cfg.Timeout,
+		// A redirect is reported as a failed delivery rather than followed.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &Deliverer{store: store, client: client, cfg: cfg}
+}
+
+// Run delivers until ctx is done.
+func (d *Deliverer) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		// The lease covers sending the whole batch, with room to spare.
+		lease := d.cfg.Timeout*time.Duration((d.cfg.BatchSize+d.cfg.Concurrency-1)/d.cfg.Concurrency) + 30*time.Second
+		claimed, err := d.store.ClaimDeliveries(ctx, d.cfg.BatchSize, lease)
+		if err != nil {
+			log.Printf("Failed to claim webhook deliveries: %v", err)
+		}
+		d.deliverAll(ctx, claimed)
+
+		wait := d.cfg.PollInterval
+		if len(claimed) > 0 {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+func (d *Deliverer) deliverAll(ctx context.Context, deliveries []repository.ClaimedDelivery) {
+	sem := make(chan struct{}, d.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(c *repository.ClaimedDelivery) {
+			defer func() { <-sem; wg.Done() }()
+			d.Deliver(ctx, c)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// Deliver makes one attempt at a claimed delivery and records the outcome.
+func (d *Deliverer) Deliver(ctx context.Context, c *repository.ClaimedDelivery) {
+	start := time.Now()
+	status, err := d.send(ctx, c)
+	attempts := c.Attempts + 1
+
+	outcome := "success"
+	var backoff time.Duration
+	dead := false
+	switch {
+	case err == nil:
+		webhookDeliveryLatency.Observe(time.Since(c.CreatedAt).Seconds())
+	case attempts >= d.cfg.MaxAttempts:
+		outcome, dead = "dead", true
+		log.Printf("Webhook delivery %d to %s failed after %d attempts, giving up: %v", c.ID, c.URL, attempts, err)
+	default:
+		outcome, backoff = "retry", d.backoff(attempts)
+		log.Printf("Webhook delivery %d to %s failed, attempt %d, retrying in %s: %v", c.ID, c.URL, attempts, backoff, err)
+	}
+	webhookDeliveryDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	webhookDeliveriesTotal.WithLabelValues(c.EventType, outcome).Inc()
+
+	if err := d.store.RecordDeliveryAttempt(ctx, c.ID, status, err, backoff, dead); err != nil {
+		// The lease expires and the delivery is attempted again.
+		log.Printf("Failed to record webhook delivery %d: %v", c.ID, err)
+	}
+}
+
+// send POSTs the delivery and returns the response status, if any. Any 2xx
+// response is success; redirects are not followed.
+func (d *Deliverer) send(ctx context.Context, c *repository.ClaimedDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(c.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gin-prometheus-grafana-webhooks/1")
+	req.Header.Set(EventHeader, c.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(c.ID, 10))
+	req.Header.Set(MessageHeader, strconv.FormatInt(c.MessageID, 10))
+	req.Header.Set(SignatureHeader, Sign(c.Secret, time.Now(), c.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	b := d.cfg.BaseBackoff
+	for i := 1; i < attempts && b < d.cfg.MaxBackoff; i++ {
+		b *= 2
+	}
+	return min(b, d.cfg.MaxBackoff)
+}
@@ -0,0 +1,237 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gin-prometheus-grafana/internal/models"
+	"gin-prometheus-grafana/internal/repository"
+)
+
+// attempt is one call to RecordDeliveryAttempt.
+type attempt struct {
+	id         int64
+	statusCode int
+	err        error
+	retryAfter time.Duration
+	dead       bool
+}
+
+// fakeDeliveryStore hands out the deliveries it is given once and records
+// every attempt.
+type fakeDeliveryStore struct {
+	mu       sync.Mutex
+	pending  []repository.ClaimedDelivery
+	attempts []attempt
+}
+
+func (s *fakeDeliveryStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.ClaimedDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.pending))
+	claimed := s.pending[:n]
+	s.pending = s.pending[n:]
+	return claimed, nil
+}
+
+func (s *fakeDeliveryStore) RecordDeliveryAttempt(ctx context.Context, id int64, statusCode int, attemptErr error, retryAfter time.Duration, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, attempt{id, statusCode, attemptErr, retryAfter, dead})
+	return nil
+}
+
+func (s *fakeDeliveryStore) only(t *testing.T) attempt {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1: %+v", len(s.attempts), s.attempts)
+	}
+	return s.attempts[0]
+}
+
+// testConfig allows private targets because httptest servers listen on
+// loopback.
+var testConfig = DelivererConfig{
+	PollInterval:        10 * time.Millisecond,
+	BatchSize:           10,
+	Concurrency:         2,
+	Timeout:             time.Second,
+	MaxAttempts:         3,
+	BaseBackoff:         time.Second,
+	MaxBackoff:          time.Minute,
+	AllowPrivateTargets: true,
+}
+
+func delivery(url string, attempts int) *repository.ClaimedDelivery {
+	return &repository.ClaimedDelivery{
+		WebhookDelivery: models.WebhookDelivery{
+			ID:        7,
+			MessageID: 42,
+			EventType: models.WebhookEventBookUpdated,
+			Payload:   []byte(`{"id":42,"type":"book.updated"}`),
+			Attempts:  attempts,
+			CreatedAt: time.Now(),
+		},
+		URL:    url,
+		Secret: "whsec_test",
+	}
+}
+
+func TestDeliverSuccess(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	store := &fakeDeliveryStore{}
+	NewDeliverer(store, testConfig).Deliver(context.Background(), delivery(receiver.URL, 0))
+
+	a := store.only(t)
+	if a.id != 7 || a.statusCode != http.StatusAccepted || a.err != nil || a.dead {
+		t.Errorf("attempt = %+v, want a successful 202", a)
+	}
+	if got.Method != http.MethodPost || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("request = %s with Content-Type %q", got.Method, got.Header.Get("Content-Type"))
+	}
+	if got.Header.Get(EventHeader) != models.WebhookEventBookUpdated || got.Header.Get(DeliveryHeader) != "7" || got.Header.Get(MessageHeader) != "42" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	if err := Verify("whsec_test", got.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestDeliverServerErrorBacksOff(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	for attempts, want := range []time.Duration{time.Second, 2 * time.Second} {
+		store := &fakeDeliveryStore{}
+		NewDeliverer(store, testConfig).Deliver(context.Background(), delivery(receiver.URL, attempts))
+
+		a := store.only(t)
+		if a.statusCode != http.StatusServiceUnavailable || a.err == nil || a.dead {
+			t.Errorf("attempt %d = %+v, want a retryable 503", attempts+1, a)
+		}
+		if a.retryAfter != want {
+			t.Errorf("attempt %d retries after %s, want %s", attempts+1, a.retryAfter, want)
+		}
+	}
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := &fakeDeliveryStore{}
+	NewDeliverer(store, testConfig).Deliver(context.Background(), delivery(receiver.URL, testConfig.MaxAttempts-1))
+
+	a := store.only(t)
+	if !a.dead || a.retryAfter != 0 || a.statusCode != http.StatusInternalServerError {
+		t.Errorf("attempt = %+v, want the delivery marked dead", a)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	store := &fakeDeliveryStore{}
+	NewDeliverer(store, testConfig).Deliver(context.Background(), delivery(receiver.URL, 0))
+
+	a := store.only(t)
+	if followed {
+		t.Error("redirect was followed")
+	}
+	if a.statusCode != http.StatusTemporaryRedirect || a.err == nil {
+		t.Errorf("attempt = %+v, want a failed 307", a)
+	}
+}
+
+func TestDeliverRefusesPrivateTargets(t *testing.T) {
+	reached := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer receiver.Close()
+
+	cfg := testConfig
+	cfg.AllowPrivateTargets = false
+	store := &fakeDeliveryStore{}
+	NewDeliverer(store, cfg).Deliver(context.Background(), delivery(receiver.URL, 0))
+
+	a := store.only(t)
+	if reached {
+		t.Error("delivery reached a loopback receiver")
+	}
+	if !errors.Is(a.err, ErrForbiddenTarget) || a.statusCode != 0 {
+		t.Errorf("attempt = %+v, want ErrForbiddenTarget", a)
+	}
+}
+
+func TestRunDeliversClaimedBatch(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Header.Get(DeliveryHeader)] = true
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	store := &fakeDeliveryStore{}
+	for i := int64(1); i <= 5; i++ {
+		d := delivery(receiver.URL, 0)
+		d.ID = i
+		store.pending = append(store.pending, *d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewDeliverer(store, testConfig).Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		store.mu.Lock()
+		n := len(store.attempts)
+		store.mu.Unlock()
+		if n == 5 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	for i := 1; i <= 5; i++ {
+		if !seen[strconv.Itoa(i)] {
+			t.Errorf("delivery %d was not sent", i)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrForbiddenTarget means a webhook URL points at an address webhooks may
+// not reach, such as the loopback interface, a private network or the cloud
+// metadata service.
+var ErrForbiddenTarget = errors.New("webhook URL must not resolve to a loopback, private or link-local address")
+
+// forbiddenPrefixes are the ranges not already covered by the net/netip
+// predicates in forbiddenAddr.
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which maps onto IPv4
+}
+
+func forbiddenAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetGuard keeps webhooks away from internal addresses, so that
+// registering a webhook cannot be used to make the server send signed
+// requests into its own network. URLs are checked when a webhook is saved,
+// and the Deliverer checks again when it connects, since DNS may have
+// changed in between.
+type TargetGuard struct {
+	// AllowPrivate disables the checks, for development and tests against
+	// local receivers.
+	AllowPrivate bool
+	// Resolver looks up host names; nil means net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// CheckURL resolves the URL's host and fails with ErrForbiddenTarget if any
+// of its addresses is forbidden.
+func (g TargetGuard) CheckURL(ctx context.Context, rawURL string) error {
+	if g.AllowPrivate {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if forbiddenAddr(addr) {
+			return ErrForbiddenTarget
+		}
+		return nil
+	}
+
+	resolver := g.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host %q: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if forbiddenAddr(addr) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// control is a net.Dialer Control hook that refuses connections to forbidden
+// addresses. It sees the address actually being dialled, after resolution.
+func (g TargetGuard) control(network, address string, _ syscall.RawConn) error {
+	if g.AllowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if forbiddenAddr(addrPort.Addr()) {
+		return ErrForbiddenTarget
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTargetGuardCheckURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		forbidden bool
+	}{
+		{"https://93.184.215.14/hooks", false},
+		{"http://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:8080/", false},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://127.0.0.1:8080/", true},
+		{"http://localhost/", true},
+		{"http://10.1.2.3/", true},
+		{"http://172.16.0.1/", true},
+		{"http://192.168.1.1/", true},
+		{"http://100.64.0.1/", true},
+		{"http://0.0.0.0/", true},
+		{"http://[::1]/", true},
+		{"http://[fe80::1]/", true},
+		{"http://[fd00::1]/", true},
+		{"http://[::ffff:127.0.0.1]/", true},
+		{"http://224.0.0.1/", true},
+	}
+	for _, tt := range tests {
+		err := TargetGuard{}.CheckURL(context.Background(), tt.url)
+		if tt.forbidden && !errors.Is(err, ErrForbiddenTarget) {
+			t.Errorf("%s: err = %v, want ErrForbiddenTarget", tt.url, err)
+		}
+		if !tt.forbidden && err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+		}
+	}
+
+	if err := (TargetGuard{AllowPrivate: true}).CheckURL(context.Background(), "http://127.0.0.1/"); err != nil {
+		t.Errorf("AllowPrivate: %v", err)
+	}
+}
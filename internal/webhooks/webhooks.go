@@ -0,0 +1,162 @@
+// Package webhooks delivers book lifecycle events to partner HTTP endpoints.
+// Events reach it through the outbox, as consumer OutboxConsumer with a relay
+// of its own: a Dispatcher turns each outbox message into one pending delivery
+// per subscribed webhook, and a Deliverer sends those deliveries, signed with
+// the webhook's secret, retrying failures with exponential backoff until they
+// succeed or are declared dead.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-prometheus-grafana/internal/outbox"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema creates the webhook tables. Deliveries are unique per webhook and
+// outbox message, so a message relayed twice is only delivered once.
+const Schema = `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		event_types TEXT[] NOT NULL,
+		secret TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		message_id BIGINT NOT NULL,
+		event_type VARCHAR(64) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		last_status_code INTEGER,
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		delivered_at TIMESTAMPTZ,
+		UNIQUE (webhook_id, message_id)
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at, id) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+`
+
+// OutboxConsumer is the outbox consumer whose relay feeds the Dispatcher.
+const OutboxConsumer = "webhooks"
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	MessageHeader   = "X-Webhook-Message-ID"
+)
+
+// Sign returns the signature header value for body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Including the
+// timestamp in the signed content lets receivers reject replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+func mac(secret, t string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Verify checks a signature header produced by Sign, rejecting it if its
+// timestamp is further than tolerance from now. A zero tolerance skips the
+// timestamp check. Receivers written in Go can use it directly.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	ts, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return errors.New("malformed signature header")
+	}
+	if tolerance > 0 {
+		if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+			return errors.New("signature timestamp outside tolerance")
+		}
+	}
+	expected := mac(secret, t, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return errors.New("signature mismatch")
+}
+
+// GenerateSecret returns a random signing secret for webhooks created
+// without one.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Envelope is the JSON body of a delivery. ID is the outbox message ID and
+// is the same on every retry and redelivery, so receivers can deduplicate
+// on it.
+type Envelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enqueuer stores pending deliveries; it is implemented by
+// repository.WebhookRepository.
+type Enqueuer interface {
+	EnqueueDeliveries(ctx context.Context, messageID int64, eventType string, payload []byte) (int, error)
+}
+
+// Dispatcher is an outbox.Publisher that queues a delivery of each message
+// for every active webhook subscribed to its topic.
+type Dispatcher struct {
+	store Enqueuer
+}
+
+func NewDispatcher(store Enqueuer) *Dispatcher {
+	return &Dispatcher{store: store}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, m outbox.Message) error {
+	body, err := json.Marshal(Envelope{ID: m.ID, Type: m.Topic, CreatedAt: m.CreatedAt, Data: m.Payload})
+	if err != nil {
+		return err
+	}
+	if _, err := d.store.EnqueueDeliveries(ctx, m.ID, m.Topic, body); err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	header := Sign("secret", now, body)
+
+	if !strings.HasPrefix(header, "t=") || !strings.Contains(header, ",v1=") {
+		t.Fatalf("header = %q", header)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		ok        bool
+	}{
+		{"valid", "secret", header, body, time.Minute, true},
+		{"no tolerance", "secret", Sign("secret", now.Add(-time.Hour), body), body, 0, true},
+		{"rotated secret", "secret", Sign("old", now, body) + "," + strings.Split(header, ",")[1], body, time.Minute, true},
+		{"wrong secret", "other", header, body, time.Minute, false},
+		{"tampered body", "secret", header, []byte(`{"id":2}`), time.Minute, false},
+		{"too old", "secret", Sign("secret", now.Add(-10*time.Minute), body), body, 5 * time.Minute, false},
+		{"from the future", "secret", Sign("secret", now.Add(10*time.Minute), body), body, 5 * time.Minute, false},
+		{"missing signature", "secret", "t=" + strings.TrimPrefix(strings.Split(header, ",")[0], "t="), body, time.Minute, false},
+		{"malformed", "secret", "garbage", body, time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.tolerance)
+			if tt.ok && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("Verify accepted the signature")
+			}
+		})
+	}
+}
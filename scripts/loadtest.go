@@ -95,7 +95,7 @@ var sampleBooks = []Book{
 	{Title: "Refactoring", Author: "Martin Fowler", ISBN: "9780201485677", Price: 47.99, PublishedAt: "1999-07-08T00:00:00Z"},
 	{Title: "Head First Design Patterns", Author: "Eric Freeman", ISBN: "9780596007126", Price: 44.99, PublishedAt: "2004-10-25T00:00:00Z"},
 	{Title: "Clean Architecture", Author: "Robert C. Martin", ISBN: "9780134494166", Price: 42.99, PublishedAt: "2017-09-20T00:00:00Z"},
-	{Title: "Effective Go", Author: "Go Team", ISBN: "9781234567890", Price: 35.99, PublishedAt: "2020-01-15T00:00:00Z"},
+	{Title: "Effective Go", Author: "Go Team", ISBN: "9781234567897", Price: 35.99, PublishedAt: "2020-01-15T00:00:00Z"},
 	{Title: "Concurrency in Go", Author: "Katherine Cox-Buday", ISBN: "9781491941195", Price: 39.99, PublishedAt: "2017-07-19T00:00:00Z"},
 	{Title: "Go in Action", Author: "William Kennedy", ISBN: "9781617291784", Price: 44.99, PublishedAt: "2015-11-04T00:00:00Z"},
 	{Title: "Learning Go", Author: "Jon Bodner", ISBN: "9781492077213", Price: 49.99, PublishedAt: "2021-03-02T00:00:00Z"},
@@ -157,11 +157,25 @@ func initializeBooks(stats *Stats) {
 	fmt.Printf("Initialized with %d books\n", getBookCount())
 }
 
+// randomISBN returns a random ISBN-13 in the 978 range with a valid check digit
+func randomISBN() string {
+	base := fmt.Sprintf("978%09d", rand.Intn(1000000000))
+	sum := 0
+	for i, c := range base {
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return fmt.Sprintf("%s%d", base, (10-sum%10)%10)
+}
+
 func createBook(stats *Stats, silent ...bool) {
 	book := sampleBooks[rand.Intn(len(sampleBooks))]
 	
-	// Make ISBN unique by adding random suffix
-	book.ISBN = book.ISBN + fmt.Sprintf("%d", rand.Intn(10000))
+	// Make ISBN unique while keeping a valid ISBN-13 checksum
+	book.ISBN = randomISBN()
 	
 	// Randomize price slightly
 	book.Price = book.Price + float64(rand.Intn(20)) - 10
@@ -182,7 +196,10 @@ func createBook(stats *Stats, silent ...bool) {
 	defer resp.Body.Close()
 	
 	if resp.StatusCode == http.StatusCreated {
-		var createdBook Book
+		// Only the ID is needed; the price comes back as an amount/currency object
+		var createdBook struct {
+			ID int `json:"id"`
+		}
 		body, _ := io.ReadAll(resp.Body)
 		if err := json.Unmarshal(body, &createdBook); err == nil {
 			addBookID(createdBook.ID)
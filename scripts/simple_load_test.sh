@@ -49,13 +49,28 @@ check_api() {
     fi
 }
 
+# Function to generate a random ISBN-13 with a valid check digit
+random_isbn() {
+    local base="978$(printf '%04d%05d' $((RANDOM % 10000)) $((RANDOM % 100000)))"
+    local sum=0
+    for ((i = 0; i < 12; i++)); do
+        local digit=${base:$i:1}
+        if ((i % 2 == 1)); then
+            sum=$((sum + digit * 3))
+        else
+            sum=$((sum + digit))
+        fi
+    done
+    echo "${base}$(((10 - sum % 10) % 10))"
+}
+
 # Function to create a random book
 create_book() {
     local book_index=$((RANDOM % ${#BOOKS[@]}))
     local book_data="${BOOKS[$book_index]}"
     
-    # Add random suffix to ISBN to make it unique
-    local unique_isbn=$(echo "$book_data" | jq -r '.isbn')$((RANDOM % 10000))
+    # Replace the ISBN with a random valid one to make it unique
+    local unique_isbn=$(random_isbn)
     local modified_book=$(echo "$book_data" | jq --arg isbn "$unique_isbn" '.isbn = $isbn')
     
     curl -s -X POST "$API_URL" \